@@ -3,48 +3,97 @@ package main
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/rollmelette/rollmelette"
 )
 
-func (a *GameApplication) handleBuildArmy(
+func (a *Game) handleBuildArmy(
 	metadata rollmelette.Metadata,
 	inputPayload BuildArmyPayload,
 ) error {
+	team, build, err := a.buildOrder(metadata.MsgSender, inputPayload)
+	if err != nil {
+		return err
+	}
+	team.Builds = append(team.Builds, build)
+
+	return nil
+}
+
+// buildOrder checks the player can give the build or disband order and returns it without
+// storing it
+func (a *Game) buildOrder(player common.Address, inputPayload BuildArmyPayload) (*Team, *BuildArmyInput, error) {
 	if a.state.Turn != "build" {
-		return fmt.Errorf("cant build an army outside build phase")
+		return nil, nil, fmt.Errorf("cant build an army outside build phase")
+	}
+	team, ok := a.state.Players[inputPayload.Owner]
+	if !ok || team.Player != player {
+		return nil, nil, fmt.Errorf("cant build another player's army")
+	}
+	var err error
+	inputPayload.Position, inputPayload.SubPosition, err = a.resolveDestination(inputPayload.Position, inputPayload.SubPosition)
+	if err != nil {
+		return nil, nil, err
 	}
 	if !a.state.Board[inputPayload.Position].SupplyCenter {
-		return fmt.Errorf("cant build an army outside a suply center")
+		return nil, nil, fmt.Errorf("cant build an army outside a suply center")
 	}
 	if a.state.Board[inputPayload.Position].Occupied && inputPayload.Delete == 0 {
-		return fmt.Errorf("cant build an army in occupied region")
+		return nil, nil, fmt.Errorf("cant build an army in occupied region")
 	}
 	if !a.state.Board[inputPayload.Position].Occupied && inputPayload.Delete != 0 {
-		return fmt.Errorf("cant delete an army in empty region")
+		return nil, nil, fmt.Errorf("cant delete an army in empty region")
+	}
+	if inputPayload.Delete != 0 {
+		unit, ok := a.state.Units[inputPayload.Delete]
+		if !ok || unit.Power != team.Name || unit.Position != inputPayload.Position {
+			return nil, nil, fmt.Errorf("no unit %v of %v in %v", inputPayload.Delete, team.Name, inputPayload.Position)
+		}
+	}
+	if team.Name != a.state.Board[inputPayload.Position].Owner {
+		return nil, nil, fmt.Errorf("cant build an army in a territory you dont own")
 	}
-	if a.state.Players[metadata.MsgSender].Name != a.state.Board[inputPayload.Position].Owner {
-		return fmt.Errorf("cant build an army in a territory you dont own")
+	if a.variant().BuildRule == HomeBuilds && a.state.Board[inputPayload.Position].Home != team.Name && inputPayload.Delete == 0 {
+		return nil, nil, fmt.Errorf("cant build an army outside your home supply centers")
 	}
 	if inputPayload.Type == "navy" && !a.state.Board[inputPayload.Position].Coastal {
-		return fmt.Errorf("cant build a navy in a landlocked territory")
+		return nil, nil, fmt.Errorf("cant build a navy in a landlocked territory")
 	}
-	if a.state.Players[metadata.MsgSender].Name != inputPayload.Owner {
-		return fmt.Errorf("cant build another player's army")
+	if inputPayload.Delete == 0 {
+		var coast string
+		coast, err = checkBuildCoast(a.state.Board[inputPayload.Position], inputPayload.Type, inputPayload.SubPosition)
+		if err != nil {
+			return nil, nil, err
+		}
+		inputPayload.SubPosition = coast
 	}
-	if len(a.state.Players[metadata.MsgSender].Armies) >= a.state.Players[metadata.MsgSender].Bases && inputPayload.Delete == 0 {
-		return fmt.Errorf(("cant build another army without extra supply centers"))
+	if len(team.Armies) >= team.Bases && inputPayload.Delete == 0 {
+		return nil, nil, fmt.Errorf(("cant build another army without extra supply centers"))
 	}
 
 	build := BuildArmyInput{
 		Info:   inputPayload,
-		Player: metadata.MsgSender,
+		Player: player,
 	}
-	a.state.Players[metadata.MsgSender].Builds = append(a.state.Players[metadata.MsgSender].Builds, &build)
+	return team, &build, nil
+}
 
-	return nil
+// adjustBases hands the supply centers occupied once the year's moves are over to the powers
+// occupying them and counts again the centers of every power, the powers build or disband up to
+// them in the winter adjustment
+func (a *Game) adjustBases() {
+	for _, unit := range a.state.Units {
+		region := a.state.Board[unit.Position]
+		if region.SupplyCenter {
+			region.Owner = unit.Power
+		}
+	}
+	for _, team := range a.state.Players {
+		team.Bases = a.state.centerCount(team.Name)
+	}
 }
 
-func BuildUnits(a *GameApplication) {
+func BuildUnits(a *Game) {
 
 	for _, player := range a.state.Players {
 		if len(player.Builds) == 0 {
@@ -53,24 +102,27 @@ func BuildUnits(a *GameApplication) {
 		for _, order := range player.Builds {
 			if order.Info.Delete != 0 {
 				a.state.Board[order.Info.Position].Occupied = false
-				delete(a.state.Players[order.Player].Armies, order.Info.Delete)
+				delete(player.Armies, order.Info.Delete)
 				delete(a.state.Units, order.Info.Delete)
 			} else {
 				a.state.Board[order.Info.Position].Occupied = true
-				a.state.Players[order.Player].Armies[UnitID] = order.Info.Position
-				a.state.Units[UnitID] = &Unit{
-					ID:       UnitID,
-					Type:     order.Info.Type,
-					Position: order.Info.Position,
-					Owner:    order.Player,
+				unitID := a.state.NextUnitID
+				player.Armies[unitID] = order.Info.Position
+				a.state.Units[unitID] = &Unit{
+					ID:          unitID,
+					Type:        order.Info.Type,
+					Position:    order.Info.Position,
+					SubPosition: order.Info.SubPosition,
+					Owner:       order.Player,
+					Power:       player.Name,
 					CurrentOrder: Orders{
-						UnitID:     UnitID,
+						UnitID:     unitID,
 						Ordertype:  "hold",
 						OrderOwner: "",
 					},
 				}
 
-				UnitID += 1
+				a.state.NextUnitID += 1
 			}
 		}
 		player.Builds = nil
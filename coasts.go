@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+)
+
+// fleetNeighbors lists the locations a navy in the region can move to, from the given coast when
+// the region has separate coasts
+func (r *Region) fleetNeighbors(coast string) []string {
+	if len(r.Coasts) != 0 {
+		return r.Coasts[coast]
+	}
+	return r.FleetNeighbors
+}
+
+// coastlines lists the locations a navy reaches from any coast of the region
+func (r *Region) coastlines() []string {
+	if len(r.Coasts) == 0 {
+		return r.FleetNeighbors
+	}
+	var neighbors []string
+	for _, coast := range sortedKeys(r.Coasts) {
+		neighbors = append(neighbors, r.Coasts[coast]...)
+	}
+	return neighbors
+}
+
+// isReachable tells whether a unit of the given type can move from the region to the other one,
+// armies follow land borders and navies follow coastlines from the coast they are on
+// A navy reaches a region with separate coasts if it reaches any of them
+func isReachable(From *Region, coast string, unitType string, To string) bool {
+	if unitType != "navy" {
+		for _, neighbor := range From.ArmyNeighbors {
+			if neighbor == To {
+				return true
+			}
+		}
+		return false
+	}
+	for _, neighbor := range From.fleetNeighbors(coast) {
+		if region, _ := splitLocation(neighbor); region == To {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCoasts validates the coasts of a navy moving, or retreating, into the region and returns the
+// coast it ends on
+// A navy always leaves from the coast it is on and must name the coast it moves into when the
+// region has separate coasts
+func (a *Game) checkCoasts(unit *Unit, fromCoast string, toRegion string, toCoast string) (string, error) {
+	if unit.Type != "navy" {
+		return "", nil
+	}
+	if fromCoast != "" && fromCoast != unit.SubPosition {
+		return "", fmt.Errorf("your navy is not on that coast")
+	}
+
+	from := a.state.Board[unit.Position]
+	if !isReachable(from, unit.SubPosition, unit.Type, toRegion) {
+		if len(from.Coasts) != 0 {
+			return "", fmt.Errorf("cant reach this region from this harbor")
+		}
+		return "", fmt.Errorf("cant move to non adjacent territory")
+	}
+
+	target := a.state.Board[toRegion]
+	if len(target.Coasts) == 0 {
+		if toCoast != "" {
+			return "", fmt.Errorf("region has no separate coasts: %v", toRegion)
+		}
+		return "", nil
+	}
+	if toCoast == "" {
+		return "", fmt.Errorf("need to specify the coast to move into")
+	}
+	if _, ok := target.Coasts[toCoast]; !ok {
+		return "", fmt.Errorf("region has no such coast: %v", location(toRegion, toCoast))
+	}
+	for _, neighbor := range from.fleetNeighbors(unit.SubPosition) {
+		if neighbor == location(toRegion, toCoast) {
+			return toCoast, nil
+		}
+	}
+	return "", fmt.Errorf("cant move to non adjacent harbor")
+}
+
+// checkBuildCoast returns the coast a unit built in the region sits on, navies built in a region
+// with separate coasts must name one of them
+func checkBuildCoast(region *Region, unitType string, coast string) (string, error) {
+	if unitType != "navy" || len(region.Coasts) == 0 {
+		return "", nil
+	}
+	if _, ok := region.Coasts[coast]; !ok {
+		return "", fmt.Errorf("need to specify the coast to build the navy on")
+	}
+	return coast, nil
+}
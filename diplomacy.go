@@ -5,35 +5,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/rollmelette/rollmelette"
 )
 
 // State of the game Board and turn type
+// Players holds the team of every power keyed by the power's name, a player may control several
+// History, Messages and Treaties are left out of the state reports and served through their own queries
 type GameState struct {
-	Board       map[string]*Region       `json:"map"`
-	Units       map[int]*Unit            `json:"units"`
-	Players     map[common.Address]*Team `json:"players"`
-	Turn        string                   `json:"turn"`
-	MoveCounter bool                     `json:"MoveCounter"`
+	Board       map[string]*Region `json:"map"`
+	Units       map[int]*Unit      `json:"units"`
+	Players     map[string]*Team   `json:"players"`
+	Turn        string             `json:"turn"`
+	MoveCounter bool               `json:"MoveCounter"`
+	Year        int                `json:"year"`
+	NextUnitID  int                `json:"-"`
+	History     []*PhaseRecord     `json:"-"`
+	Messages    []*Message         `json:"-"`
+	Treaties    []*Treaty          `json:"-"`
 }
 
 // The board is built of regions wich have a name, are either occupied or not, are owned by a player, are either a base or not and are connected to other regions
+// Armies move along ArmyNeighbors and navies along FleetNeighbors, Neighbors holds both
+// Regions with separate coasts keep the fleet adjacency of each coast in Coasts instead, keyed
+// by the coast's name, e.g. NC for Spain/NC
+// Abbreviation is the name the region goes by in standard order notation, e.g. STP, and Aliases
+// the other names players may refer to it by
 type Region struct {
-	Name         string              `json:"name"`
-	Occupied     bool                `json:"occupied"`
-	Owner        string              `json:"owner"`
-	SupplyCenter bool                `json:"supplyCenter"`
-	Coastal      bool                `json:"coastal"`
-	Sea          bool                `json:"sea"`
-	Neighbors    []*string           `json:"frontiers"`
-	SubRegions   map[string][]string `json:"subRegions"`
-}
-
-type SubRegion struct {
-	Name      string   `json:"name"`
-	Neighbors []string `json:"neighbors"`
+	Name           string              `json:"name"`
+	Abbreviation   string              `json:"abbreviation"`
+	Aliases        []string            `json:"aliases,omitempty"`
+	Occupied       bool                `json:"occupied"`
+	Owner          string              `json:"owner"`
+	Home           string              `json:"home"`
+	SupplyCenter   bool                `json:"supplyCenter"`
+	Coastal        bool                `json:"coastal"`
+	Sea            bool                `json:"sea"`
+	Neighbors      []*string           `json:"frontiers"`
+	ArmyNeighbors  []string            `json:"armyFrontiers"`
+	FleetNeighbors []string            `json:"fleetFrontiers"`
+	Coasts         map[string][]string `json:"coasts"`
 }
 
 // Movement struct to handle move turns
@@ -51,13 +64,22 @@ type SupportOrder struct {
 }
 
 // The Team includes the team name, the Player address and a map of all the current armies this player has
+// Powers nobody plays are in civil disorder, their Player is the zero address and their units hold
+// EncryptionKey is the X25519 public key private messages to the power are encrypted to
 type Team struct {
-	Name   string            `json:"name"`
-	Player common.Address    `json:"player"`
-	Armies map[int]string    `json:"armies"`
-	Bases  int               `json:"bases"`
-	Ready  bool              `json:"ready"`
-	Builds []*BuildArmyInput `json:"builds"`
+	Name      string            `json:"name"`
+	Player    common.Address    `json:"player"`
+	Armies    map[int]string    `json:"armies"`
+	Bases     int               `json:"bases"`
+	Ready     bool              `json:"ready"`
+	Builds    []*BuildArmyInput `json:"builds"`
+	DrawVote  bool              `json:"drawVote"`
+	Phases    int               `json:"phases"`
+	NMRs      int               `json:"nmrs"`
+	Hidden    bool              `json:"hidden"`
+	Submitted bool              `json:"-"`
+
+	EncryptionKey []byte `json:"encryptionKey"`
 }
 
 type BuildArmyInput struct {
@@ -74,36 +96,61 @@ const (
 	ReadyOrders InputKind = "ReadyOrders"
 	DeleteArmy  InputKind = "DeleteArmy"
 	Retreat     InputKind = "Retreat"
+	VoteDraw    InputKind = "VoteDraw"
+	CreateGame  InputKind = "CreateGame"
+	JoinGame    InputKind = "JoinGame"
+	CancelGame  InputKind = "CancelGame"
+	LeaveGame   InputKind = "LeaveGame"
+	Withdraw    InputKind = "Withdraw"
+	SendMessage InputKind = "SendMessage"
+	RegisterKey InputKind = "RegisterKey"
+
+	ProposeTreaty  InputKind = "ProposeTreaty"
+	AcceptTreaty   InputKind = "AcceptTreaty"
+	WithdrawTreaty InputKind = "WithdrawTreaty"
+
+	SubmitOrders InputKind = "SubmitOrders"
+
+	CreateTournament InputKind = "CreateTournament"
+	JoinTournament   InputKind = "JoinTournament"
+	StartRound       InputKind = "StartRound"
 )
 
+// Input is the envelope of every advance payload
+// GameID selects the game the input is meant to, the game created with the application is game 0
+// TournamentID selects the tournament for the tournament inputs
 type Input struct {
-	Kind    InputKind       `json:"kind"`
-	Payload json.RawMessage `json:"payload"`
+	Kind         InputKind       `json:"kind"`
+	GameID       int             `json:"gameID"`
+	TournamentID int             `json:"tournamentID"`
+	Payload      json.RawMessage `json:"payload"`
 }
 
-// Give order payload is simply an Orders struct
+// Give order payload is simply an Orders struct, move orders can also be sent as a string in
+// standard notation, e.g. "A PAR - BUR"
 type GiveOrderPayload = Orders
 
 // Unit struct represents an army unit
 // Type is either army or navy
 // Position is the name of the region it currently is
-// Owner is the name of the Team that Owns this unit
+// SubPosition is the coast a navy is on in a region with separate coasts, empty otherwise
+// Owner is the address of the player controlling the unit and Power the name of the Team it belongs to
 type Unit struct {
 	ID           int            `json:"ID"`
 	Type         string         `json:"type"`
 	Position     string         `json:"position"`
 	SubPosition  string         `json:"subPosition"`
 	Owner        common.Address `json:"owner"`
+	Power        string         `json:"power"`
 	CurrentOrder Orders         `json:"currentOrder"`
 	Retreating   string         `json:"retreating"`
 }
 
-var UnitID int = 0
-
 // BuildArmyPayload is the payload for the building army input
 // Type of the army either army or navy
 // Position it is been built or deleted
 // Owner of  the army
+// SubPosition is the coast a navy is built on when the region has separate coasts
 // Delete is a bool indicating if the player is deleting an army
 type BuildArmyPayload struct {
 	Type        string `json:"type"`
@@ -113,6 +160,8 @@ type BuildArmyPayload struct {
 	Delete      int    `json:"delete"`
 }
 
+// Orders given to a unit, ToSubRegion and FromSubRegion are the coasts of a navy's move when
+// it moves into or out of a region with separate coasts
 type Orders struct {
 	UnitID        int    `json:"unitID"`
 	Ordertype     string `json:"orderType"`
@@ -124,10 +173,10 @@ type Orders struct {
 }
 
 type MoveOrder struct {
-	Unit       *Unit
-	FromRegion string
-	ToRegion   string
-	SubRegion  string
+	Unit        *Unit
+	FromRegion  string
+	ToRegion    string
+	ToSubRegion string
 }
 
 type RetreatOrderPayload struct {
@@ -139,9 +188,57 @@ type RetreatOrderPayload struct {
 
 type PassTurnPayload string
 
+// GameApplication hosts every game created in the rollup
+// profiles keeps every player's record across games
 type GameApplication struct {
-	state     GameState
-	RoundTime int
+	games            map[int]*Game
+	nextGameID       int
+	profiles         map[common.Address]*PlayerProfile
+	tournaments      map[int]*Tournament
+	nextTournamentID int
+}
+
+type GameStatus string
+
+// Game lifecycle
+const (
+	GameOpen      GameStatus = "open"
+	GameRunning   GameStatus = "running"
+	GameFinished  GameStatus = "finished"
+	GameCancelled GameStatus = "cancelled"
+)
+
+// Game is a single board, it stays open until every power has a player and then runs until
+// someone wins or the survivors agree on a draw, the Scoring system then gives each power its Scores
+// Seats lists the players in the order they joined, seat i plays the Variant's i-th power
+// Players is the number of seats, powers left without a seat are Unassigned: either in civil
+// disorder or doubled up with the players seated
+// In a FogOfWar game players only see the units and orders next to their units and supply centers
+// Press is the diplomacy allowed between powers: full, public or gunboat
+// Treaties lets powers sign treaties the engine enforces
+// EntryFee is what each player pays to join and Pot is what is currently held in the Escrow
+// wallet account, both in the ERC-20 Token or in Ether when the token is the zero address
+type Game struct {
+	ID         int                `json:"id"`
+	Status     GameStatus         `json:"status"`
+	Creator    common.Address     `json:"creator"`
+	EntryFee   *big.Int           `json:"entryFee"`
+	Token      common.Address     `json:"token"`
+	Pot        *big.Int           `json:"pot"`
+	Escrow     common.Address     `json:"escrow"`
+	Seats      []common.Address   `json:"seats"`
+	Players    int                `json:"players"`
+	Unassigned string             `json:"unassigned"`
+	FogOfWar   bool               `json:"fogOfWar"`
+	Press      string             `json:"press"`
+	Treaties   bool               `json:"treaties"`
+	Scoring    string             `json:"scoring"`
+	Result     *GameResult        `json:"result"`
+	Scores     map[string]float64 `json:"scores"`
+	RoundTime  int                `json:"roundTime"`
+	Variant    string             `json:"variant"`
+	Tournament int                `json:"tournament"`
+	state      GameState
 }
 
 // ConflictOutcome represents the outcome of a conflict between two units' orders
@@ -151,31 +248,65 @@ type ConflictOutcome struct {
 	Bounced bool  // Indicates if the conflict resulted in units bouncing back to their original positions
 }
 
-var SubRegionsList = [3]string{"Bulgaria", "St Petersburg", "Spain"}
-
-func NewGameApplication(Austria common.Address,
-	England common.Address,
-	France common.Address,
-	Germany common.Address,
-	Italy common.Address,
-	Russia common.Address,
-	Turkey common.Address,
-	RoundTime int,
-) *GameApplication {
-	Game := GameApplication{
-
-		RoundTime: RoundTime,
-		state: GameState{
-			Board:       initializeRegions(),
-			Players:     initializePlayers(Austria, England, France, Germany, Italy, Russia, Turkey),
-			Units:       initializeUnits(Austria, England, France, Germany, Italy, Russia, Turkey),
-			Turn:        "move",
-			MoveCounter: false,
-		},
+// NewGameApplication creates the application with game 0 already running between the given
+// players, seat i plays the variant's i-th power
+func NewGameApplication(variant string, players []common.Address, RoundTime int) (*GameApplication, error) {
+	definition, err := variantDefinition(variant)
+	if err != nil {
+		return nil, err
+	}
+	if len(players) != len(definition.powers()) {
+		return nil, fmt.Errorf("variant %v needs %v players, got %v", variant, len(definition.powers()), len(players))
 	}
-	UnitID = len(Game.state.Units) + 1
-	return &Game
 
+	app := NewLobbyApplication()
+	game := newGame(app.nextGameID, common.Address{}, big.NewInt(0), RoundTime)
+	game.Variant = variant
+	game.Seats = players
+	game.Players = len(players)
+	game.start()
+	app.games[game.ID] = game
+	app.nextGameID++
+	return app, nil
+}
+
+// NewLobbyApplication creates the application without any game, games are created through inputs
+func NewLobbyApplication() *GameApplication {
+	return &GameApplication{
+		games:       make(map[int]*Game),
+		profiles:    make(map[common.Address]*PlayerProfile),
+		tournaments: make(map[int]*Tournament),
+	}
+}
+
+func newGame(id int, creator common.Address, entryFee *big.Int, roundTime int) *Game {
+	return &Game{
+		ID:        id,
+		Status:    GameOpen,
+		Creator:   creator,
+		EntryFee:  entryFee,
+		Pot:       big.NewInt(0),
+		Escrow:    escrowAddress(id),
+		Scoring:   DefaultScoring,
+		Press:     DefaultPress,
+		RoundTime: roundTime,
+		Variant:   ClassicVariant,
+	}
+}
+
+// start sets up the board of the game's variant once every seat is taken
+func (a *Game) start() {
+	definition := a.variant().definition()
+	a.state = GameState{
+		Board:       initializeRegions(definition),
+		Players:     initializePlayers(definition, a.powerSeats()),
+		Units:       initializeUnits(definition, a.powerSeats()),
+		Turn:        "move",
+		MoveCounter: false,
+		Year:        1901,
+	}
+	a.state.NextUnitID = len(a.state.Units) + 1
+	a.Status = GameRunning
 }
 
 func (a *GameApplication) Advance(
@@ -184,6 +315,9 @@ func (a *GameApplication) Advance(
 	deposit rollmelette.Deposit,
 	payload []byte,
 ) error {
+	if deposit != nil {
+		return a.handleDeposit(env, metadata, deposit, payload)
+	}
 	var input Input
 	err := json.Unmarshal(payload, &input)
 	if err != nil {
@@ -191,12 +325,120 @@ func (a *GameApplication) Advance(
 	}
 
 	switch input.Kind {
-	case MoveArmy:
-		var inputPayload GiveOrderPayload
+	case CreateGame:
+		var inputPayload CreateGamePayload
 		err = json.Unmarshal(input.Payload, &inputPayload)
 		if err != nil {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
+		game, err := a.handleCreateGame(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+		return report(env, game.publicView())
+	case JoinGame:
+		game, err := a.handleJoinGame(env, metadata, deposit, input.GameID)
+		if err != nil {
+			return err
+		}
+		return report(env, game.publicView())
+	case CancelGame:
+		game, err := a.handleCancelGame(env, metadata, input.GameID)
+		if err != nil {
+			return err
+		}
+		return report(env, game.publicView())
+	case LeaveGame:
+		game, err := a.handleLeaveGame(env, metadata, input.GameID)
+		if err != nil {
+			return err
+		}
+		return report(env, game.publicView())
+	case Withdraw:
+		var inputPayload WithdrawPayload
+		if len(input.Payload) != 0 {
+			err = json.Unmarshal(input.Payload, &inputPayload)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal payload: %w", err)
+			}
+		}
+		payout, err := a.handleWithdraw(env, metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+		return report(env, payout)
+	case CreateTournament:
+		var inputPayload CreateTournamentPayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		tournament, err := a.handleCreateTournament(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+		return report(env, tournament)
+	case JoinTournament:
+		tournament, err := a.handleJoinTournament(metadata, input.TournamentID)
+		if err != nil {
+			return err
+		}
+		return report(env, tournament)
+	case StartRound:
+		var inputPayload StartRoundPayload
+		if len(input.Payload) != 0 {
+			err = json.Unmarshal(input.Payload, &inputPayload)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal payload: %w", err)
+			}
+		}
+		tournament, err := a.handleStartRound(metadata, input.TournamentID, inputPayload)
+		if err != nil {
+			return err
+		}
+		return report(env, tournament)
+	}
+
+	game, ok := a.games[input.GameID]
+	if !ok {
+		return fmt.Errorf("game not found: %v", input.GameID)
+	}
+	if game.Status != GameRunning {
+		return fmt.Errorf("game is not running")
+	}
+	err = game.advance(env, metadata, input)
+	if err != nil {
+		return err
+	}
+	if game.Result != nil {
+		err = a.settleGame(env, game)
+		if err != nil {
+			return err
+		}
+		if game.Tournament != 0 {
+			err = a.checkTournament(env, a.tournaments[game.Tournament])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return game.reportState(env, metadata.MsgSender)
+}
+
+// advance handles the inputs sent to a running game
+func (a *Game) advance(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	input Input,
+) error {
+	var err error
+	switch input.Kind {
+	case MoveArmy:
+		inputPayload, err := a.orderPayload(input.Payload)
+		if err != nil {
+			return err
+		}
 		err = a.handleMoveArmy(metadata, inputPayload)
 		if err != nil {
 			return err
@@ -217,7 +459,17 @@ func (a *GameApplication) Advance(
 		if err != nil {
 			return fmt.Errorf("failed to unmarshal payload: %w", err)
 		}
-		err = a.ReadyOrders(metadata)
+		err = a.ReadyOrders(env, metadata)
+		if err != nil {
+			return err
+		}
+	case SubmitOrders:
+		var inputPayload SubmitOrdersPayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		err = a.handleSubmitOrders(env, metadata, inputPayload)
 		if err != nil {
 			return err
 		}
@@ -231,75 +483,112 @@ func (a *GameApplication) Advance(
 		if err != nil {
 			return err
 		}
+	case VoteDraw:
+		var inputPayload VoteDrawPayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		err = a.handleVoteDraw(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+	case SendMessage:
+		var inputPayload SendMessagePayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		err = a.handleSendMessage(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+	case RegisterKey:
+		var inputPayload RegisterKeyPayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		err = a.handleRegisterKey(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+	case ProposeTreaty:
+		var inputPayload ProposeTreatyPayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		err = a.handleProposeTreaty(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+	case AcceptTreaty:
+		var inputPayload TreatyPayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		err = a.handleAcceptTreaty(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
+	case WithdrawTreaty:
+		var inputPayload TreatyPayload
+		err = json.Unmarshal(input.Payload, &inputPayload)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+		err = a.handleWithdrawTreaty(metadata, inputPayload)
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("invalid input kind: %v", input.Kind)
 	}
 
-	return a.Inspect(env, nil)
-}
-
-func (a *GameApplication) Inspect(env rollmelette.EnvInspector, payload []byte) error {
-
-	bytes, err := json.Marshal(a.state)
-	if err != nil {
-		return fmt.Errorf("failed to marshal: %w", err)
-	}
-
-	env.Report(bytes)
 	return nil
 }
 
-func isConnected(From *Region, To *string) bool {
-	for _, city := range From.Neighbors {
-		c := city
-		if *c == *To {
-			return true
-		}
-	}
-	return false
-}
-
-func isSubRegionConnected(from []string, to string) bool {
-	for _, neighbor := range from {
-		if neighbor == to {
-			return true
-		}
-	}
-	return false
-}
-
-func (a *GameApplication) ReadyOrders(
+func (a *Game) ReadyOrders(
+	env rollmelette.Env,
 	metadata rollmelette.Metadata,
 ) error {
-	if a.state.Players[metadata.MsgSender] == nil {
+	teams := a.state.teamsOf(metadata.MsgSender)
+	if len(teams) == 0 {
 		return fmt.Errorf("msg sender is not a player")
 	}
 
-	a.state.Players[metadata.MsgSender].Ready = true
+	for _, team := range teams {
+		team.Ready = true
+	}
 	for _, player := range a.state.Players {
-		if !player.Ready {
+		if !player.Ready && !player.inCivilDisorder() {
 			return nil
 		}
 	}
-	err := a.passTurn()
+	err := a.passTurn(env)
 	if err != nil {
-		return fmt.Errorf("pass turn function not working")
+		return fmt.Errorf("pass turn function not working: %w", err)
 	}
 	return nil
 }
 
-func (a *GameApplication) processMoves() {
+func (a *Game) processMoves() {
 	moveOrders := a.prepareMoves()
 	a.executeMoves(moveOrders)
 	ResolveMovementConflicts(&a.state)
 }
 
-func (a *GameApplication) passTurn() error {
+func (a *Game) passTurn(env rollmelette.Env) error {
 	for _, player := range a.state.Players {
 		player.Ready = false
 	}
+	label := a.state.PhaseLabel()
+	snapshot := a.takeSnapshot()
 
 	if a.state.Turn == "move" {
+		a.recordSubmissions()
 		// Register all departures
 		a.processMoves()
 		ResetOrders(a)
@@ -318,6 +607,8 @@ func (a *GameApplication) passTurn() error {
 	} else if a.state.Turn == "build" {
 		BuildUnits(a)
 		a.state.Turn = "move"
+		a.state.Year++
+		a.expireTreaties()
 	} else if a.state.Turn == "retreats" {
 		resolveRetreats(a)
 		ResetOrders(a)
@@ -328,13 +619,32 @@ func (a *GameApplication) passTurn() error {
 		}
 	}
 
-	return nil
+	if a.state.Turn == "build" {
+		a.adjustBases()
+		a.checkVictory()
+	}
+
+	result := a.phaseResult(label, snapshot)
+	a.recordPhase(result, snapshot)
+	if !a.FogOfWar {
+		return emitNotice(env, PhaseResultNotice, result)
+	}
+	// notices are public, in a fog of war game they only announce the phase and no filtered notice
+	// is sent per player: encrypting one for each power would need randomness no node can keep
+	// secret, what each player saw of the phase is served by the history query instead
+	return emitNotice(env, PhaseResultNotice, PhaseResult{
+		Phase:         label,
+		Orders:        []OrderResult{},
+		Builds:        []*BuildArmyInput{},
+		Dislodged:     []Dislodgement{},
+		CenterChanges: []CenterChange{},
+	})
 }
 
 func main() {
 	ctx := context.Background()
 	opts := rollmelette.NewRunOpts()
-	app := new(GameApplication)
+	app := NewLobbyApplication()
 	err := rollmelette.Run(ctx, opts, app)
 	if err != nil {
 		slog.Error("application error", "error", err)
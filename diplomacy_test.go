@@ -1,16 +1,25 @@
 package main
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/rollmelette/rollmelette"
 	"github.com/stretchr/testify/suite"
 )
 
-var payload = common.Hex2Bytes("deadbeef")
+var payload = []byte(`{"query": "state"}`)
 
 // var msgSender = common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafafa")
 var Austria = common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafaf1")
@@ -35,7 +44,8 @@ type MyApplicationSuite struct {
 }
 
 func (s *MyApplicationSuite) SetupTest() {
-	app := NewGameApplication(Austria, England, France, Germany, Italy, Russia, Turkey, 5)
+	app, err := NewGameApplication(ClassicVariant, []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey}, 5)
+	s.Require().NoError(err)
 	s.tester = rollmelette.NewTester(app)
 }
 
@@ -161,6 +171,7 @@ func (s *MyApplicationSuite) TestBuildArmy() {
 		Type:     "army",
 		Position: "London",
 		Owner:    England,
+		Power:    "England",
 		CurrentOrder: Orders{
 			UnitID:     23,
 			Ordertype:  "hold",
@@ -229,9 +240,6 @@ func (s *MyApplicationSuite) TestUnitBounce() {
 	s.Nil(r1.Err)
 	s.Nil(r2.Err)
 
-	err := json.Unmarshal([]byte(r2.Reports[0].Payload), &currentState)
-	s.Nil(err, "Unmarshal should not error out")
-
 	//checking if the orders were issued
 	expectedUnitsOrders1 := Orders{
 		UnitID:     4,
@@ -249,8 +257,17 @@ func (s *MyApplicationSuite) TestUnitBounce() {
 		FromRegion: "Brest",
 	}
 
+	//each player only sees their own orders
+	err := json.Unmarshal([]byte(r1.Reports[0].Payload), &currentState)
+	s.Nil(err, "Unmarshal should not error out")
 	s.Equal(expectedUnitsOrders1, currentState.Units[4].CurrentOrder)
-	s.Equal(expectedUnitsOrders2, currentState.Units[8].CurrentOrder)
+
+	var franceView GameState
+	err = json.Unmarshal([]byte(r2.Reports[0].Payload), &franceView)
+	s.Nil(err, "Unmarshal should not error out")
+	s.Equal(expectedUnitsOrders2, franceView.Units[8].CurrentOrder)
+	s.Equal("hold", franceView.Units[4].CurrentOrder.Ordertype)
+	s.Equal("", franceView.Units[4].CurrentOrder.ToRegion)
 
 	report, result := s.PassTurn()
 
@@ -595,11 +612,11 @@ func (s *MyApplicationSuite) TestConvoyDebug() {
 }
 
 func (s *MyApplicationSuite) TestMovingFromSubRegions() {
-	input1 := `{"kind": "MoveArmy", "payload" : {"UnitID": 17, "OrderType": "move", "OrderOwner": "Russia", "ToRegion": "Norway", "FromRegion": "St Petersburg", "FromSubRegion": "South Coast"}}`
+	input1 := `{"kind": "MoveArmy", "payload" : {"UnitID": 17, "OrderType": "move", "OrderOwner": "Russia", "ToRegion": "Norway", "FromRegion": "St Petersburg", "FromSubRegion": "SC"}}`
 	r1 := s.tester.Advance(Russia, []byte(input1))
 	s.ErrorContains(r1.Err, "cant reach this region from this harbor")
 
-	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 17, "OrderType": "move", "OrderOwner": "Russia", "ToRegion": "Finland", "FromRegion": "St Petersburg", "FromSubRegion": "South Coast"}}`
+	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 17, "OrderType": "move", "OrderOwner": "Russia", "ToRegion": "Finland", "FromRegion": "St Petersburg", "FromSubRegion": "SC"}}`
 	r1 = s.tester.Advance(Russia, []byte(input1))
 	s.Nil(r1.Err)
 
@@ -620,13 +637,13 @@ func (s *MyApplicationSuite) TestMovingIntoSubRegions() {
 
 	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Bulgaria", "FromRegion": "Black Sea", "ToSubRegion": ""}}`
 	r1 = s.tester.Advance(Turkey, []byte(input1))
-	s.ErrorContains(r1.Err, "need to specify the sub region and can't move directly between sub regions")
+	s.ErrorContains(r1.Err, "need to specify the coast to move into")
 
-	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Bulgaria", "FromRegion": "Black Sea", "ToSubRegion": "South Coast"}}`
+	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Bulgaria", "FromRegion": "Black Sea", "ToSubRegion": "SC"}}`
 	r1 = s.tester.Advance(Turkey, []byte(input1))
 	s.ErrorContains(r1.Err, "cant move to non adjacent harbor")
 
-	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Bulgaria", "FromRegion": "Black Sea", "FromSubRegion": "", "ToSubRegion": "North Coast"}}`
+	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Bulgaria", "FromRegion": "Black Sea", "FromSubRegion": "", "ToSubRegion": "EC"}}`
 	r1 = s.tester.Advance(Turkey, []byte(input1))
 	s.Nil(result)
 
@@ -636,7 +653,7 @@ func (s *MyApplicationSuite) TestMovingIntoSubRegions() {
 	s.Nil(err, "Unmarshal should not error out")
 
 	s.Equal("Bulgaria", currentState.Units[22].Position)
-	s.Equal("North Coast", currentState.Units[22].SubPosition)
+	s.Equal("EC", currentState.Units[22].SubPosition)
 	s.Nil(result)
 
 }
@@ -909,4 +926,1686 @@ func (s *MyApplicationSuite) TestMoveFromFlasePosition() {
 func (s *MyApplicationSuite) TestInspect() {
 	result := s.tester.Inspect(payload)
 	s.Nil(result.Err)
+
+	result = s.tester.Inspect(common.Hex2Bytes("deadbeef"))
+	s.ErrorContains(result.Err, "failed to unmarshal query")
+
+	result = s.tester.Inspect([]byte(`{"query": "nothing"}`))
+	s.ErrorContains(result.Err, "invalid query")
+}
+
+func (s *MyApplicationSuite) TestInspectHidesOtherPlayersOrders() {
+	input := `{"kind": "MoveArmy", "payload" : {"UnitID": 4, "OrderType": "move", "OrderOwner": "England", "ToRegion": "Wales", "FromRegion": "London"}}`
+	r := s.tester.Advance(England, []byte(input))
+	s.Nil(r.Err)
+	r = s.tester.Advance(England, PassTurnPayloadSetup)
+	s.Nil(r.Err)
+
+	var view GameState
+	result := s.tester.Inspect(payload)
+	s.Nil(result.Err)
+	err := json.Unmarshal(result.Reports[0].Payload, &view)
+	s.Nil(err, "Unmarshal should not error out")
+	s.Equal("hold", view.Units[4].CurrentOrder.Ordertype)
+
+	query := fmt.Sprintf(`{"query": "state", "args": {"player": "%v"}}`, England.Hex())
+	result = s.tester.Inspect([]byte(query))
+	s.Nil(result.Err)
+	err = json.Unmarshal(result.Reports[0].Payload, &view)
+	s.Nil(err, "Unmarshal should not error out")
+	s.Equal("move", view.Units[4].CurrentOrder.Ordertype)
+	s.Equal("Wales", view.Units[4].CurrentOrder.ToRegion)
+
+	var summary GameSummary
+	result = s.tester.Inspect([]byte(`{"query": "summary"}`))
+	s.Nil(result.Err)
+	err = json.Unmarshal(result.Reports[0].Payload, &summary)
+	s.Nil(err, "Unmarshal should not error out")
+	s.Len(summary.Players, 7)
+	for _, player := range summary.Players {
+		s.Equal(player.Name == "England", player.Ready)
+	}
+}
+
+func (s *MyApplicationSuite) inspectQuery(query string, value any) {
+	result := s.tester.Inspect([]byte(query))
+	s.Nil(result.Err)
+	err := json.Unmarshal(result.Reports[0].Payload, value)
+	s.Nil(err, "Unmarshal should not error out")
+}
+
+func (s *MyApplicationSuite) TestInspectQueries() {
+	var phase PhaseInfo
+	s.inspectQuery(`{"query": "phase"}`, &phase)
+	s.Equal(PhaseInfo{Year: 1901, Season: Spring, Turn: "move", Label: "S1901M"}, phase)
+
+	_, err := s.PassTurn()
+	s.Nil(err)
+	_, err = s.PassTurn()
+	s.Nil(err)
+
+	s.inspectQuery(`{"query": "phase"}`, &phase)
+	s.Equal("W1901A", phase.Label)
+
+	var history []string
+	s.inspectQuery(`{"query": "history"}`, &history)
+	s.Equal([]string{"S1901M", "F1901M"}, history)
+
+	input := `{"kind": "BuildArmy", "payload" : {"Type": "army", "Position": "London", "Owner": "England", "Delete": 4}}`
+	r := s.tester.Advance(England, []byte(input))
+	s.Nil(r.Err)
+
+	var builds []*BuildArmyInput
+	s.inspectQuery(fmt.Sprintf(`{"query": "builds", "args": {"player": "%v"}}`, England.Hex()), &builds)
+	s.Len(builds, 1)
+	s.Equal("London", builds[0].Info.Position)
+
+	result := s.tester.Inspect([]byte(`{"query": "builds"}`))
+	s.ErrorContains(result.Err, "player not found")
+
+	_, err = s.PassTurn()
+	s.Nil(err)
+	s.inspectQuery(`{"query": "phase"}`, &phase)
+	s.Equal("S1902M", phase.Label)
+
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"power": "England"}}`, &units)
+	s.Len(units, 2)
+	s.Equal(5, units[0].ID)
+	s.Equal(6, units[1].ID)
+
+	var region Region
+	s.inspectQuery(`{"query": "region", "args": {"region": "London"}}`, &region)
+	s.Equal("England", region.Owner)
+	s.False(region.Occupied)
+
+	result = s.tester.Inspect([]byte(`{"query": "region", "args": {"region": "Atlantis"}}`))
+	s.ErrorContains(result.Err, "region not found")
+
+	var board map[string]*Region
+	s.inspectQuery(`{"query": "board"}`, &board)
+	s.True(board["Paris"].SupplyCenter)
+
+	var players []PlayerSummary
+	s.inspectQuery(`{"query": "players"}`, &players)
+	s.Len(players, 7)
+	s.Equal("Austria", players[0].Name)
+	s.Equal(Austria, players[0].Player)
+}
+
+func (s *MyApplicationSuite) TestLegalOrders() {
+	var orders []Orders
+	s.inspectQuery(`{"query": "legalOrders", "args": {"unit": 17}}`, &orders)
+
+	var moves []string
+	for _, order := range orders {
+		if order.Ordertype == "move" {
+			moves = append(moves, order.ToRegion)
+		}
+	}
+	s.ElementsMatch([]string{"Livonia", "Gulf of Bothnia", "Finland"}, moves)
+	s.Equal("hold", orders[0].Ordertype)
+
+	//every generated order is accepted by the move handler
+	for _, order := range orders {
+		payload, err := json.Marshal(order)
+		s.Nil(err)
+		input := fmt.Sprintf(`{"kind": "MoveArmy", "payload": %s}`, payload)
+		result := s.tester.Advance(Russia, []byte(input))
+		s.Nil(result.Err, order)
+	}
+
+	s.inspectQuery(`{"query": "legalOrders", "args": {"unit": 5}}`, &orders)
+	for _, order := range orders {
+		s.NotEqual("convoy move", order.Ordertype)
+		s.NotEqual("Irish Sea", order.ToRegion)
+	}
+
+	result := s.tester.Inspect([]byte(`{"query": "legalOrders", "args": {"unit": 99}}`))
+	s.ErrorContains(result.Err, "unit not found")
+}
+
+// passTurnResult readies every player and returns the result of the input that passed the turn
+func (s *MyApplicationSuite) passTurnResult() rollmelette.TestAdvanceResult {
+	var result rollmelette.TestAdvanceResult
+	for _, player := range []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey} {
+		result = s.tester.Advance(player, PassTurnPayloadSetup)
+		s.Nil(result.Err)
+	}
+	return result
+}
+
+func (s *MyApplicationSuite) phaseResultNotice(result rollmelette.TestAdvanceResult) PhaseResult {
+	s.Len(result.Notices, 1)
+	var notice struct {
+		Kind    NoticeKind  `json:"kind"`
+		Payload PhaseResult `json:"payload"`
+	}
+	err := json.Unmarshal(result.Notices[0].Payload, &notice)
+	s.Nil(err, "Unmarshal should not error out")
+	s.Equal(PhaseResultNotice, notice.Kind)
+	return notice.Payload
+}
+
+func (s *MyApplicationSuite) TestPhaseResultNotice() {
+	input1 := `{"kind": "MoveArmy", "payload" : {"UnitID": 4, "OrderType": "move", "OrderOwner": "England", "ToRegion": "English Channel", "FromRegion": "London"}}`
+	input2 := `{"kind": "MoveArmy", "payload" : {"UnitID": 8, "OrderType": "move", "OrderOwner": "France", "ToRegion": "English Channel", "FromRegion": "Brest"}}`
+	input3 := `{"kind": "MoveArmy", "payload" : {"UnitID": 1, "OrderType": "move", "OrderOwner": "Austria", "ToRegion": "Tyrolia", "FromRegion": "Vienna"}}`
+	s.Nil(s.tester.Advance(England, []byte(input1)).Err)
+	s.Nil(s.tester.Advance(France, []byte(input2)).Err)
+	s.Nil(s.tester.Advance(Austria, []byte(input3)).Err)
+
+	notice := s.phaseResultNotice(s.passTurnResult())
+	s.Equal("S1901M", notice.Phase)
+	s.Len(notice.Orders, 22)
+	s.Equal(ResultSucceeded, notice.Orders[0].Result)
+	s.Equal("Tyrolia", notice.Orders[0].Order.ToRegion)
+	s.Equal("England", notice.Orders[3].Power)
+	s.Equal(ResultBounced, notice.Orders[3].Result)
+	s.Equal(ResultBounced, notice.Orders[7].Result)
+	s.Empty(notice.Dislodged)
+
+	input1 = `{"kind": "MoveArmy", "payload" : {"UnitID": 1, "OrderType": "move", "OrderOwner": "Austria", "ToRegion": "Venice", "FromRegion": "Tyrolia"}}`
+	input2 = `{"kind": "MoveArmy", "payload" : {"UnitID": 3, "OrderType": "support move", "OrderOwner": "Austria", "ToRegion": "Venice", "FromRegion": "Tyrolia"}}`
+	s.Nil(s.tester.Advance(Austria, []byte(input1)).Err)
+	s.Nil(s.tester.Advance(Austria, []byte(input2)).Err)
+
+	notice = s.phaseResultNotice(s.passTurnResult())
+	s.Equal("F1901M", notice.Phase)
+	s.Equal(ResultDislodged, notice.Orders[13].Result)
+	s.Equal([]Dislodgement{{UnitID: 14, Power: "Italy", Region: "Venice", AttackedFrom: "Tyrolia"}}, notice.Dislodged)
+	s.Equal([]CenterChange{{Region: "Venice", From: "Italy", To: "Austria"}}, notice.CenterChanges)
+
+	notice = s.phaseResultNotice(s.passTurnResult())
+	s.Equal("F1901R", notice.Phase)
+	s.Len(notice.Orders, 1)
+	s.Equal(ResultDisbanded, notice.Orders[0].Result)
+}
+
+func (s *MyApplicationSuite) TestPhaseHistory() {
+	input := `{"kind": "MoveArmy", "payload" : {"UnitID": 4, "OrderType": "move", "OrderOwner": "England", "ToRegion": "Wales", "FromRegion": "London"}}`
+	s.Nil(s.tester.Advance(England, []byte(input)).Err)
+	s.passTurnResult()
+	s.passTurnResult()
+
+	var record PhaseRecord
+	s.inspectQuery(`{"query": "history", "args": {"phase": "S1901M"}}`, &record)
+	s.Equal("S1901M", record.Phase)
+	s.Equal("London", record.Before[3].Position)
+	s.Equal("Wales", record.After[3].Position)
+	s.Equal("England", record.After[3].Power)
+	s.Equal("move", record.Orders[3].Order.Ordertype)
+	s.Equal(ResultSucceeded, record.Orders[3].Result)
+
+	s.inspectQuery(`{"query": "history", "args": {"phase": "F1901M"}}`, &record)
+	s.Equal("Wales", record.Before[3].Position)
+	s.Equal("hold", record.Orders[3].Order.Ordertype)
+
+	result := s.tester.Inspect([]byte(`{"query": "history", "args": {"phase": "S1902M"}}`))
+	s.ErrorContains(result.Err, "phase not found")
+}
+
+var players = []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey}
+
+func (s *MyApplicationSuite) TestEntryFeeGame() {
+	s.tester.RelayAppAddress(common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafafa"))
+
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"entryFee": 100}}`))
+	s.Nil(result.Err)
+	var game Game
+	err := json.Unmarshal(result.Reports[0].Payload, &game)
+	s.Nil(err, "Unmarshal should not error out")
+	s.Equal(1, game.ID)
+	s.Equal(GameOpen, game.Status)
+
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	result = s.tester.Advance(Austria, join)
+	s.ErrorContains(result.Err, "requires an Ether deposit")
+	s.depositRefused(s.tester.DepositEther(Austria, big.NewInt(99), join), "deposit must be exactly the entry fee of 100")
+	s.depositRefused(s.tester.DepositEther(Austria, big.NewInt(1), []byte(`{"kind": "VoteDraw"}`)), "deposits are only accepted to join a game")
+	s.depositRefused(s.tester.DepositEther(Austria, big.NewInt(1), []byte(`not json`)), "failed to unmarshal input")
+
+	// refused deposits stay in the wallet of the depositor, who can take them back
+	withdraw := []byte(`{"kind": "Withdraw"}`)
+	result = s.tester.Advance(Austria, withdraw)
+	s.Nil(result.Err)
+	s.Len(result.Vouchers, 1)
+	var payout Payout
+	s.Nil(json.Unmarshal(result.Reports[0].Payload, &payout))
+	s.Equal(big.NewInt(101), payout.Amount)
+	result = s.tester.Advance(Austria, withdraw)
+	s.ErrorContains(result.Err, "nothing to withdraw")
+
+	for i, player := range players {
+		result = s.tester.DepositEther(player, big.NewInt(100), join)
+		s.Nil(result.Err)
+		err = json.Unmarshal(result.Reports[0].Payload, &game)
+		s.Nil(err, "Unmarshal should not error out")
+		s.Equal(i+1, len(game.Seats))
+	}
+	s.Equal(GameRunning, game.Status)
+	s.Equal(big.NewInt(700), game.Pot)
+
+	s.depositRefused(s.tester.DepositEther(Austria, big.NewInt(100), join), "game is not open to new players")
+
+	move := `{"kind": "MoveArmy", "gameID": 1, "payload" : {"UnitID": 4, "OrderType": "move", "OrderOwner": "England", "ToRegion": "Wales", "FromRegion": "London"}}`
+	s.Nil(s.tester.Advance(England, []byte(move)).Err)
+
+	vote := []byte(`{"kind": "VoteDraw", "gameID": 1, "payload": {"draw": true}}`)
+	for _, player := range players[:6] {
+		result = s.tester.Advance(player, vote)
+		s.Nil(result.Err)
+		s.Empty(result.Vouchers)
+	}
+	result = s.tester.Advance(Turkey, vote)
+	s.Nil(result.Err)
+	s.Len(result.Vouchers, 7)
+	s.Len(result.Notices, 1)
+
+	var notice struct {
+		Kind    NoticeKind `json:"kind"`
+		Payload GameEnd    `json:"payload"`
+	}
+	err = json.Unmarshal(result.Notices[0].Payload, &notice)
+	s.Nil(err, "Unmarshal should not error out")
+	s.Equal(GameEndNotice, notice.Kind)
+	s.Len(notice.Payload.Result.Draw, 7)
+	for _, payout := range notice.Payload.Payouts {
+		s.Equal(big.NewInt(100), payout.Amount)
+	}
+
+	s.inspectQuery(`{"query": "game", "args": {"game": 1}}`, &game)
+	s.Equal(GameFinished, game.Status)
+	s.Equal(0, game.Pot.Sign())
+
+	result = s.tester.Advance(Turkey, vote)
+	s.ErrorContains(result.Err, "game is not running")
+}
+
+func (s *MyApplicationSuite) TestCancelGameRefunds() {
+	s.tester.RelayAppAddress(common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafafa"))
+
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"entryFee": 100}}`))
+	s.Nil(result.Err)
+
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	s.Nil(s.tester.DepositEther(England, big.NewInt(100), join).Err)
+	s.Nil(s.tester.DepositEther(France, big.NewInt(100), join).Err)
+
+	s.depositRefused(s.tester.DepositEther(England, big.NewInt(100), join), "player already joined this game")
+	s.Len(s.tester.Advance(England, []byte(`{"kind": "Withdraw"}`)).Vouchers, 1)
+
+	// players get their fee back without waiting for the creator
+	leave := []byte(`{"kind": "LeaveGame", "gameID": 1}`)
+	result = s.tester.Advance(France, leave)
+	s.Nil(result.Err)
+	s.Len(result.Vouchers, 1)
+	result = s.tester.Advance(France, leave)
+	s.ErrorContains(result.Err, "msg sender is not a player")
+
+	cancel := []byte(`{"kind": "CancelGame", "gameID": 1}`)
+	result = s.tester.Advance(England, cancel)
+	s.ErrorContains(result.Err, "only the creator can cancel the game")
+
+	result = s.tester.Advance(Austria, cancel)
+	s.Nil(result.Err)
+	s.Len(result.Vouchers, 1)
+
+	var games []*Game
+	s.inspectQuery(`{"query": "games"}`, &games)
+	s.Len(games, 2)
+	s.Equal(GameCancelled, games[1].Status)
+
+	s.depositRefused(s.tester.DepositEther(Germany, big.NewInt(100), join), "game is not open to new players")
+	result = s.tester.Advance(Austria, leave)
+	s.ErrorContains(result.Err, "can't leave a game that already started")
+}
+
+// depositRefused checks the input carrying a deposit was accepted without using the deposit
+func (s *MyApplicationSuite) depositRefused(result rollmelette.TestAdvanceResult, message string) {
+	s.Nil(result.Err)
+	var refused DepositRefused
+	s.Nil(json.Unmarshal(result.Reports[0].Payload, &refused))
+	s.Contains(refused.Error, message)
+}
+
+func (s *MyApplicationSuite) TestFreeGame() {
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {}}`))
+	s.Nil(result.Err)
+
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	s.depositRefused(s.tester.DepositEther(Austria, big.NewInt(100), join), "game has no entry fee")
+
+	for _, player := range players {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+	result = s.tester.Advance(Austria, join)
+	s.ErrorContains(result.Err, "game is not open to new players")
+
+	result = s.tester.Advance(Austria, []byte(`{"kind": "MoveArmy", "gameID": 7, "payload": {}}`))
+	s.ErrorContains(result.Err, "game not found")
+
+	var view GameState
+	s.inspectQuery(fmt.Sprintf(`{"query": "state", "args": {"game": 1, "player": "%v"}}`, England.Hex()), &view)
+	s.Equal(England, view.Units[4].Owner)
+}
+
+func (s *MyApplicationSuite) TestERC20EntryFeeGame() {
+	token := common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafa01")
+	otherToken := common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafa02")
+
+	create := fmt.Sprintf(`{"kind": "CreateGame", "payload": {"entryFee": 50, "token": "%v"}}`, token.Hex())
+	result := s.tester.Advance(Austria, []byte(create))
+	s.Nil(result.Err)
+
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	s.depositRefused(s.tester.DepositEther(Austria, big.NewInt(50), join), "game requires a deposit of 50 of token")
+	s.depositRefused(s.tester.DepositERC20(otherToken, Austria, big.NewInt(50), join), "game requires a deposit of 50 of token")
+	s.depositRefused(s.tester.DepositERC20(token, Austria, big.NewInt(60), join), "deposit must be exactly the entry fee")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(`{"kind": "Withdraw", "payload": {"token": "%v"}}`, otherToken.Hex())))
+	s.Nil(result.Err)
+	s.Equal(otherToken, result.Vouchers[0].Destination)
+
+	for _, player := range players {
+		result = s.tester.DepositERC20(token, player, big.NewInt(50), join)
+		s.Nil(result.Err)
+	}
+
+	var game Game
+	s.inspectQuery(`{"query": "game", "args": {"game": 1}}`, &game)
+	s.Equal(GameRunning, game.Status)
+	s.Equal(big.NewInt(350), game.Pot)
+	s.Equal(escrowAddress(1), game.Escrow)
+
+	vote := []byte(`{"kind": "VoteDraw", "gameID": 1, "payload": {"draw": true}}`)
+	for _, player := range players {
+		result = s.tester.Advance(player, vote)
+		s.Nil(result.Err)
+	}
+	s.Len(result.Vouchers, 7)
+	for _, voucher := range result.Vouchers {
+		s.Equal(token, voucher.Destination)
+	}
+}
+
+func (s *MyApplicationSuite) TestScoringSystems() {
+	centers := map[string]int{"Austria": 10, "England": 8, "France": 8, "Germany": 4, "Italy": 4, "Russia": 0, "Turkey": 0}
+	draw := &GameResult{Draw: []string{"Austria", "England", "France", "Germany", "Italy"}}
+
+	scores := DrawSizeScoring{}.Score(centers, 34, draw)
+	s.Equal(20.0, scores["Austria"])
+	s.Equal(20.0, scores["Italy"])
+	s.Equal(0.0, scores["Russia"])
+
+	scores = SumOfSquaresScoring{}.Score(centers, 34, draw)
+	s.InDelta(100*100/260.0, scores["Austria"], 1e-9)
+	s.InDelta(100*64/260.0, scores["England"], 1e-9)
+	s.Equal(0.0, scores["Turkey"])
+
+	scores = CarnageScoring{}.Score(centers, 34, draw)
+	s.Equal(7010.0, scores["Austria"])
+	s.Equal(5508.0, scores["England"])
+	s.Equal(5508.0, scores["France"])
+	s.Equal(3504.0, scores["Germany"])
+	s.Equal(0.0, scores["Russia"])
+
+	scores = CDiploScoring{}.Score(centers, 34, draw)
+	s.Equal(49.0, scores["Austria"])
+	s.Equal(9.0, scores["France"])
+	s.Equal(0.0, scores["Turkey"])
+
+	// powers left out of the draw, like a surviving power in civil disorder, score nothing
+	partial := &GameResult{Draw: []string{"England", "France", "Germany"}}
+	scores = SumOfSquaresScoring{}.Score(centers, 34, partial)
+	s.InDelta(100*64/144.0, scores["England"], 1e-9)
+	s.Equal(0.0, scores["Austria"])
+	scores = CarnageScoring{}.Score(centers, 34, partial)
+	s.Equal(6508.0, scores["England"])
+	s.Equal(6508.0, scores["France"])
+	s.Equal(5004.0, scores["Germany"])
+	s.Equal(0.0, scores["Austria"])
+	scores = CDiploScoring{}.Score(centers, 34, partial)
+	s.Equal(28.0, scores["England"])
+	s.Equal(5.0, scores["Germany"])
+	s.Equal(0.0, scores["Austria"])
+	s.Equal(0.0, scores["Italy"])
+
+	solo := &GameResult{Winner: "England"}
+	for _, system := range scoringSystems {
+		scores = system.Score(centers, 34, solo)
+		s.Greater(scores["England"], 0.0)
+		s.Equal(0.0, scores["Austria"])
+	}
+
+	payouts := scorePayouts(big.NewInt(100), map[string]float64{"Austria": 1, "England": 1, "France": 1, "Germany": 0})
+	s.Equal(big.NewInt(34), payouts["Austria"])
+	s.Equal(big.NewInt(33), payouts["England"])
+	s.Equal(big.NewInt(33), payouts["France"])
+	s.Equal(big.NewInt(0), payouts["Germany"])
+
+	// the points follow the number of powers and centers of the variant, Chaos has 34 powers
+	chaos := map[string]int{}
+	for i := 0; i < 34; i++ {
+		chaos[fmt.Sprintf("Power%02d", i)] = 1
+	}
+	chaos["Power00"], chaos["Power01"], chaos["Power02"] = 3, 0, 0
+	scores = CarnageScoring{}.Score(chaos, 34, &GameResult{Draw: sortedKeys(chaos)})
+	s.Equal(34003.0, scores["Power00"])
+	s.Equal(0.0, scores["Power01"])
+	for power, score := range scores {
+		s.GreaterOrEqual(score, 0.0, power)
+	}
+	scores = CarnageScoring{}.Score(chaos, 34, &GameResult{Winner: "Power00"})
+	s.Equal(float64(1000*34*35/2+34), scores["Power00"])
+	scores = CDiploScoring{}.Score(chaos, 34, &GameResult{Winner: "Power00"})
+	s.Equal(float64(1+34+38), scores["Power00"])
+
+	payouts = scorePayouts(big.NewInt(90), map[string]float64{"Austria": -2976, "England": 2, "France": 1})
+	s.Equal(big.NewInt(0), payouts["Austria"])
+	s.Equal(big.NewInt(60), payouts["England"])
+	s.Equal(big.NewInt(30), payouts["France"])
+}
+
+func (s *MyApplicationSuite) TestScoringSystemPayouts() {
+	s.tester.RelayAppAddress(common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafafa"))
+
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"scoring": "elo"}}`))
+	s.ErrorContains(result.Err, "invalid scoring system")
+
+	result = s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"entryFee": 100, "scoring": "cdiplo"}}`))
+	s.Nil(result.Err)
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	for _, player := range players {
+		s.Nil(s.tester.DepositEther(player, big.NewInt(100), join).Err)
+	}
+
+	vote := []byte(`{"kind": "VoteDraw", "gameID": 1, "payload": {"draw": true}}`)
+	for _, player := range players {
+		result = s.tester.Advance(player, vote)
+		s.Nil(result.Err)
+	}
+
+	var game Game
+	s.inspectQuery(`{"query": "game", "args": {"game": 1}}`, &game)
+	s.Equal("cdiplo", game.Scoring)
+	// Russia tops the board with 4 centers and takes the 38 points bonus
+	s.Equal(1+4+38.0, game.Scores["Russia"])
+	s.Equal(4.0, game.Scores["Austria"])
+	s.Len(result.Vouchers, 7)
+}
+
+func (s *MyApplicationSuite) TestRatingsLeaderboard() {
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"scoring": "sos"}}`))
+	s.Nil(result.Err)
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	for _, player := range players {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+
+	// Only Austria submits orders before the first movement phase is adjudicated
+	input := `{"kind": "MoveArmy", "gameID": 1, "payload" : {"UnitID": 1, "OrderType": "hold", "OrderOwner": "Austria", "FromRegion": "Vienna"}}`
+	s.Nil(s.tester.Advance(Austria, []byte(input)).Err)
+	for _, player := range players {
+		s.Nil(s.tester.Advance(player, []byte(`{"kind": "ReadyOrders", "gameID": 1, "payload": ""}`)).Err)
+	}
+
+	vote := []byte(`{"kind": "VoteDraw", "gameID": 1, "payload": {"draw": true}}`)
+	for _, player := range players {
+		s.Nil(s.tester.Advance(player, vote).Err)
+	}
+
+	var leaderboard []PlayerProfile
+	s.inspectQuery(`{"query": "leaderboard"}`, &leaderboard)
+	s.Len(leaderboard, 7)
+	// Russia starts with the most centers and gets the best sum of squares score
+	s.Equal(Russia, leaderboard[0].Player)
+	s.Greater(leaderboard[0].Rating, InitialRating)
+	s.Less(leaderboard[6].Rating, InitialRating)
+
+	var profile struct {
+		PlayerProfile
+		Reliability float64 `json:"reliability"`
+	}
+	s.inspectQuery(fmt.Sprintf(`{"query": "profile", "args": {"player": "%v"}}`, Austria.Hex()), &profile)
+	s.Equal(1, profile.Games)
+	s.Equal(1, profile.Draws)
+	s.Equal(1, profile.Phases)
+	s.Equal(0, profile.NMRs)
+	s.Equal(1.0, profile.Reliability)
+
+	s.inspectQuery(fmt.Sprintf(`{"query": "profile", "args": {"player": "%v"}}`, England.Hex()), &profile)
+	s.Equal(1, profile.NMRs)
+	s.Equal(0.0, profile.Reliability)
+
+	inspect := s.tester.Inspect([]byte(`{"query": "profile", "args": {"player": "0x0000000000000000000000000000000000000001"}}`))
+	s.ErrorContains(inspect.Err, "player not found")
+}
+
+// drawGame has every player of the game vote for a draw and returns the result of the last vote
+func (s *MyApplicationSuite) drawGame(id int) rollmelette.TestAdvanceResult {
+	var game Game
+	s.inspectQuery(fmt.Sprintf(`{"query": "game", "args": {"game": %d}}`, id), &game)
+	s.Equal(GameRunning, game.Status)
+	var result rollmelette.TestAdvanceResult
+	for _, player := range game.Seats {
+		result = s.tester.Advance(player, []byte(fmt.Sprintf(`{"kind": "VoteDraw", "gameID": %d, "payload": {"draw": true}}`, id)))
+		s.Nil(result.Err)
+	}
+	return result
+}
+
+func (s *MyApplicationSuite) TestTournament() {
+	organizer := common.HexToAddress("0xfafafafafafafafafafafafafafafafafafafa00")
+	var entrants []common.Address
+	for i := 1; i <= 14; i++ {
+		entrants = append(entrants, common.HexToAddress(fmt.Sprintf("0xfafafafafafafafafafafafafafafafafafafb%02x", i)))
+	}
+
+	result := s.tester.Advance(organizer, []byte(`{"kind": "CreateTournament", "payload": {"rounds": 2, "seeding": "swiss"}}`))
+	s.ErrorContains(result.Err, "invalid seeding")
+	result = s.tester.Advance(organizer, []byte(`{"kind": "CreateTournament", "payload": {"rounds": 2}}`))
+	s.ErrorContains(result.Err, "tournament needs a seed hash")
+	// the organizer commits to the seed of the draws before anyone registers
+	seed := []byte("organizer's secret seed")
+	create := fmt.Sprintf(`{"kind": "CreateTournament", "payload": {"rounds": 2, "seedHash": "%v"}}`, crypto.Keccak256Hash(seed).Hex())
+	result = s.tester.Advance(organizer, []byte(create))
+	s.Nil(result.Err)
+
+	join := []byte(`{"kind": "JoinTournament", "tournamentID": 1}`)
+	start := []byte(`{"kind": "StartRound", "tournamentID": 1}`)
+	reveal := []byte(fmt.Sprintf(`{"kind": "StartRound", "tournamentID": 1, "payload": {"seed": "%v"}}`, base64.StdEncoding.EncodeToString(seed)))
+	for _, player := range entrants[:13] {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+	result = s.tester.Advance(organizer, start)
+	s.ErrorContains(result.Err, "tournament needs a multiple of 7 players")
+	s.Nil(s.tester.Advance(entrants[13], join).Err)
+
+	result = s.tester.Advance(entrants[0], reveal)
+	s.ErrorContains(result.Err, "only the organizer can start a round")
+	result = s.tester.Advance(organizer, start)
+	s.ErrorContains(result.Err, "seed doesn't match the tournament's seed hash")
+	result = s.tester.Advance(organizer, reveal)
+	s.Nil(result.Err)
+	result = s.tester.Advance(organizer, join)
+	s.ErrorContains(result.Err, "tournament is not open to new players")
+	result = s.tester.Advance(organizer, start)
+	s.ErrorContains(result.Err, "previous round is not finished")
+
+	s.drawGame(1)
+	s.drawGame(2)
+	s.Nil(s.tester.Advance(organizer, start).Err)
+
+	var tournament TournamentView
+	s.inspectQuery(`{"query": "tournament", "args": {"tournament": 1}}`, &tournament)
+	s.Equal([][]int{{1, 2}, {3, 4}}, tournament.Boards)
+	s.Equal(seed, tournament.Seed)
+	s.Equal(ClassicVariant, tournament.Variant)
+	s.NotEqual(common.Hash{}, tournament.Closing)
+
+	// With two boards of seven some pairs must meet again, at best four players come from one
+	// board of the first round and three from the other
+	met := make(map[pairing]int)
+	for _, id := range []int{1, 2, 3, 4} {
+		var game Game
+		s.inspectQuery(fmt.Sprintf(`{"query": "game", "args": {"game": %d}}`, id), &game)
+		s.Equal(1, game.Tournament)
+		for i, player := range game.Seats {
+			for _, other := range game.Seats[i+1:] {
+				met[pair(player, other)]++
+			}
+		}
+	}
+	repeats := 0
+	for _, count := range met {
+		repeats += count - 1
+	}
+	s.Equal(18, repeats)
+	for _, standing := range tournament.Standings {
+		s.Len(standing.Powers, 2)
+		s.NotEqual(standing.Powers[0], standing.Powers[1])
+	}
+
+	s.drawGame(3)
+	result = s.drawGame(4)
+	s.Len(result.Notices, 2)
+	var notice struct {
+		Kind    NoticeKind    `json:"kind"`
+		Payload TournamentEnd `json:"payload"`
+	}
+	err := json.Unmarshal(result.Notices[1].Payload, &notice)
+	s.Nil(err)
+	s.Equal(TournamentEndNotice, notice.Kind)
+	s.Len(notice.Payload.Standings, 14)
+	for _, standing := range notice.Payload.Standings {
+		s.Equal(2, standing.Boards)
+	}
+
+	s.inspectQuery(`{"query": "tournament", "args": {"tournament": 1}}`, &tournament)
+	s.Equal(TournamentFinished, tournament.Status)
+	result = s.tester.Advance(organizer, start)
+	s.ErrorContains(result.Err, "every round was already played")
+}
+
+func (s *MyApplicationSuite) TestMapDefinition() {
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"variant": "atlantis"}}`))
+	s.ErrorContains(result.Err, "invalid variant")
+
+	definition, err := mapDefinition(DefaultMap)
+	s.Nil(err)
+	s.Equal([]string{"Austria", "England", "France", "Germany", "Italy", "Russia", "Turkey"}, definition.Powers)
+	s.Equal(definition.Powers, variants[ClassicVariant].powers())
+
+	var board map[string]*Region
+	s.inspectQuery(`{"query": "board"}`, &board)
+	s.Len(board, len(definition.Regions))
+	s.True(board["Mid Atlantic Ocean"].Sea)
+	s.True(board["Brest"].Coastal)
+	s.True(board["Moscow"].SupplyCenter)
+	s.True(board["Moscow"].Occupied)
+	s.Equal("Russia", board["Moscow"].Home)
+	s.Equal("Russia", board["Livonia"].Owner)
+	s.Equal("", board["Livonia"].Home)
+	s.Equal("Neutral", board["Belgium"].Owner)
+	s.Equal([]string{"Aegean Sea", "Constantinople", "Greece"}, board["Bulgaria"].Coasts["SC"])
+
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"power": "Russia"}}`, &units)
+	s.Len(units, 4)
+	s.Equal("St Petersburg", units[1].Position)
+	s.Equal("SC", units[1].SubPosition)
+
+	var players []PlayerSummary
+	s.inspectQuery(`{"query": "players"}`, &players)
+	s.Equal(4, players[5].Bases)
+}
+
+func (s *MyApplicationSuite) TestMapValidation() {
+	for name, definition := range maps {
+		s.Nil(definition.Validate(), name)
+	}
+
+	broken := &MapDefinition{
+		Name:   "broken",
+		Powers: []string{"Austria", "England"},
+		Regions: []RegionDefinition{
+			{Name: "Vienna", Abbreviation: "VIE", Type: LandRegion, SupplyCenter: true, Home: "Austria", Army: []string{"Trieste", "Endinburgh"}},
+			{Name: "Trieste", Abbreviation: "vie", Type: CoastRegion, Army: []string{"Vienna"}, Fleet: []string{"Adriatic Sea"}},
+			{Name: "Adriatic Sea", Type: SeaRegion, SupplyCenter: true, Fleet: []string{"Vienna"}},
+			{Name: "Clyde", Type: "island"},
+		},
+		Units: []UnitDefinition{
+			{Power: "Austria", Type: "army", Region: "Adriatic Sea"},
+			{Power: "England", Type: "Army", Region: "Vienna"},
+		},
+	}
+	err := broken.Validate()
+	s.ErrorContains(err, "region Vienna borders unknown region Endinburgh")
+	s.ErrorContains(err, "sea region Adriatic Sea can't be a supply center")
+	s.ErrorContains(err, "fleets can move from Trieste to Adriatic Sea but not back")
+	s.ErrorContains(err, "fleets can't move from Adriatic Sea to land region Vienna")
+	s.ErrorContains(err, "region Clyde has invalid type island")
+	s.ErrorContains(err, "region Clyde can't be reached from Vienna")
+	s.ErrorContains(err, "army of Austria can't start in sea region Adriatic Sea")
+	s.ErrorContains(err, "unit of England in Vienna has invalid type Army")
+	s.ErrorContains(err, "regions Vienna and Trieste both go by vie")
+	s.ErrorContains(err, `region Adriatic Sea has invalid abbreviation ""`)
+
+	maps[broken.Name] = broken
+	defer delete(maps, broken.Name)
+	variants[broken.Name] = &Variant{Name: broken.Name, Map: broken.Name, VictoryCenters: 1, BuildRule: HomeBuilds}
+	defer delete(variants, broken.Name)
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"variant": "broken"}}`))
+	s.ErrorContains(result.Err, "map broken is not valid")
+}
+
+func (s *MyApplicationSuite) TestFleetsFollowCoastlines() {
+	// Edinburgh and Liverpool only share a land border
+	input := `{"kind": "MoveArmy", "payload" : {"UnitID": 6, "OrderType": "move", "OrderOwner": "England", "ToRegion": "Liverpool", "FromRegion": "Edinburgh"}}`
+	result := s.tester.Advance(England, []byte(input))
+	s.ErrorContains(result.Err, "cant move to non adjacent territory")
+
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 6, "OrderType": "support hold", "OrderOwner": "England", "ToRegion": "Liverpool", "FromRegion": "Edinburgh"}}`
+	result = s.tester.Advance(England, []byte(input))
+	s.ErrorContains(result.Err, "cant support hold to non adjacent territory")
+
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 5, "OrderType": "move", "OrderOwner": "England", "ToRegion": "Edinburgh", "FromRegion": "Liverpool"}}`
+	result = s.tester.Advance(England, []byte(input))
+	s.Nil(result.Err)
+
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 6, "OrderType": "support move", "OrderOwner": "England", "ToRegion": "Yorkshire", "FromRegion": "Liverpool"}}`
+	result = s.tester.Advance(England, []byte(input))
+	s.Nil(result.Err)
+
+	var orders []Orders
+	s.inspectQuery(`{"query": "legalOrders", "args": {"unit": 6}}`, &orders)
+	for _, order := range orders {
+		if order.Ordertype == "move" {
+			s.Contains([]string{"Clyde", "North Sea", "Norwegian Sea", "Yorkshire"}, order.ToRegion)
+		}
+	}
+}
+
+func (s *MyApplicationSuite) TestSplitCoasts() {
+	input := `{"kind": "MoveArmy", "payload" : {"UnitID": 17, "OrderType": "move", "OrderOwner": "Russia", "ToRegion": "Finland", "FromRegion": "St Petersburg", "FromSubRegion": "NC"}}`
+	result := s.tester.Advance(Russia, []byte(input))
+	s.ErrorContains(result.Err, "your navy is not on that coast")
+
+	var orders []Orders
+	s.inspectQuery(`{"query": "legalOrders", "args": {"unit": 17}}`, &orders)
+	var moves []string
+	for _, order := range orders {
+		if order.Ordertype == "move" {
+			s.Equal("SC", order.FromSubRegion)
+			moves = append(moves, order.ToRegion)
+		}
+	}
+	s.ElementsMatch([]string{"Finland", "Gulf of Bothnia", "Livonia"}, moves)
+
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Black Sea", "FromRegion": "Ankara"}}`
+	s.Nil(s.tester.Advance(Turkey, []byte(input)).Err)
+	s.passTurnResult()
+
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Bulgaria", "FromRegion": "Black Sea", "ToSubRegion": "NC"}}`
+	result = s.tester.Advance(Turkey, []byte(input))
+	s.ErrorContains(result.Err, "region has no such coast: Bulgaria/NC")
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Bulgaria", "FromRegion": "Black Sea", "ToSubRegion": "EC"}}`
+	s.Nil(s.tester.Advance(Turkey, []byte(input)).Err)
+	s.passTurnResult()
+	s.passTurnResult()
+
+	// From the east coast the fleet can't reach the Aegean but still borders Constantinople and Rumania
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Aegean Sea", "FromRegion": "Bulgaria"}}`
+	result = s.tester.Advance(Turkey, []byte(input))
+	s.ErrorContains(result.Err, "cant reach this region from this harbor")
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "support hold", "OrderOwner": "Turkey", "ToRegion": "Constantinople", "FromRegion": "Bulgaria"}}`
+	s.Nil(s.tester.Advance(Turkey, []byte(input)).Err)
+	input = `{"kind": "MoveArmy", "payload" : {"UnitID": 22, "OrderType": "move", "OrderOwner": "Turkey", "ToRegion": "Rumania", "FromRegion": "Bulgaria"}}`
+	s.Nil(s.tester.Advance(Turkey, []byte(input)).Err)
+	s.passTurnResult()
+
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"power": "Turkey"}}`, &units)
+	s.Equal("Rumania", units[2].Position)
+	s.Equal("", units[2].SubPosition)
+
+	board := initializeRegions(maps[DefaultMap])
+	_, err := checkBuildCoast(board["St Petersburg"], "navy", "")
+	s.ErrorContains(err, "need to specify the coast to build the navy on")
+	coast, err := checkBuildCoast(board["St Petersburg"], "navy", "NC")
+	s.Nil(err)
+	s.Equal("NC", coast)
+	coast, err = checkBuildCoast(board["St Petersburg"], "army", "NC")
+	s.Nil(err)
+	s.Equal("", coast)
+}
+
+func (s *MyApplicationSuite) TestConvoyFromSplitCoast() {
+	move := `{"kind": "MoveArmy", "payload" : {"UnitID": %v, "OrderType": "%v", "OrderOwner": "France", "ToRegion": "%v", "FromRegion": "%v"}}`
+	s.Nil(s.tester.Advance(France, []byte(fmt.Sprintf(move, 9, "move", "Spain", "Marseilles"))).Err)
+	s.Nil(s.tester.Advance(France, []byte(fmt.Sprintf(move, 8, "move", "Mid Atlantic Ocean", "Brest"))).Err)
+	s.passTurnResult()
+
+	// the Mid Atlantic borders both coasts of Spain
+	s.Nil(s.tester.Advance(France, []byte(fmt.Sprintf(move, 9, "convoy move", "North Africa", "Spain"))).Err)
+	s.Nil(s.tester.Advance(France, []byte(`{"kind": "MoveArmy", "payload" : {"UnitID": 8, "OrderType": "convoy", "OrderOwner": "France", "ToRegion": "North Africa", "FromRegion": "Spain"}}`)).Err)
+	s.passTurnResult()
+
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"power": "France"}}`, &units)
+	positions := []string{}
+	for _, unit := range units {
+		positions = append(positions, unit.Position)
+	}
+	s.Contains(positions, "North Africa")
+}
+
+func (s *MyApplicationSuite) TestVariants() {
+	for name := range variants {
+		_, err := variantDefinition(name)
+		s.Nil(err, name)
+	}
+	_, err := NewGameApplication("france-austria", []common.Address{France}, 5)
+	s.ErrorContains(err, "variant france-austria needs 2 players, got 1")
+
+	// Two player game on the standard map, the other powers' centers start neutral
+	result := s.tester.Advance(France, []byte(`{"kind": "CreateGame", "payload": {"variant": "france-austria"}}`))
+	s.Nil(result.Err)
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	s.Nil(s.tester.Advance(France, join).Err)
+	s.Nil(s.tester.Advance(Austria, join).Err)
+
+	var game Game
+	s.inspectQuery(`{"query": "game", "args": {"game": 1}}`, &game)
+	s.Equal(GameRunning, game.Status)
+	s.Equal("france-austria", game.Variant)
+
+	var board map[string]*Region
+	s.inspectQuery(`{"query": "board", "args": {"game": 1}}`, &board)
+	s.Equal("France", board["Paris"].Owner)
+	s.Equal("Neutral", board["Berlin"].Owner)
+	s.Equal("", board["Berlin"].Home)
+	s.False(board["Berlin"].Occupied)
+
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"game": 1}}`, &units)
+	s.Len(units, 6)
+	s.Equal("Vienna", units[0].Position)
+
+	// Five powers on a small invented map of their own
+	result = s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"variant": "inland-sea"}}`))
+	s.Nil(result.Err)
+	join = []byte(`{"kind": "JoinGame", "gameID": 2}`)
+	for _, player := range []common.Address{Austria, England, France, Germany} {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+	s.inspectQuery(`{"query": "game", "args": {"game": 2}}`, &game)
+	s.Equal(GameOpen, game.Status)
+	s.Nil(s.tester.Advance(Italy, join).Err)
+
+	var players []PlayerSummary
+	s.inspectQuery(`{"query": "players", "args": {"game": 2}}`, &players)
+	s.Len(players, 5)
+	s.Equal("Carthage", players[0].Name)
+	s.Equal(Germany, players[1].Player)
+	s.Equal(3, players[4].Bases)
+	s.Equal(15, variants["inland-sea"].VictoryCenters)
+}
+
+func (s *MyApplicationSuite) TestHomeBuilds() {
+	game := newGame(0, common.Address{}, big.NewInt(0), 5)
+	game.Seats = []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey}
+	game.start()
+	game.state.Turn = "build"
+	game.state.Board["Belgium"].Owner = "England"
+	game.state.Players["England"].Bases = 4
+
+	build := BuildArmyPayload{Type: "army", Position: "Belgium", Owner: "England"}
+	err := game.handleBuildArmy(rollmelette.Metadata{MsgSender: England}, build)
+	s.ErrorContains(err, "cant build an army outside your home supply centers")
+
+	variants["owned"] = &Variant{Name: "owned", Map: DefaultMap, VictoryCenters: 18, BuildRule: OwnedBuilds}
+	defer delete(variants, "owned")
+	game.Variant = "owned"
+	err = game.handleBuildArmy(rollmelette.Metadata{MsgSender: England}, build)
+	s.Nil(err)
+}
+
+func (s *MyApplicationSuite) TestWinterAdjustment() {
+	_, err := s.PassTurn()
+	s.Nil(err)
+
+	// Italy walks into Trieste as the Austrian fleet leaves it in the fall
+	move := `{"kind": "MoveArmy", "payload" : {"UnitID": %v, "OrderType": "move", "OrderOwner": "%v", "ToRegion": "%v", "FromRegion": "%v"}}`
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(move, 3, "Austria", "Albania", "Trieste"))).Err)
+	s.Nil(s.tester.Advance(Italy, []byte(fmt.Sprintf(move, 14, "Italy", "Trieste", "Venice"))).Err)
+	_, err = s.PassTurn()
+	s.Nil(err)
+
+	var board map[string]*Region
+	s.inspectQuery(`{"query": "board"}`, &board)
+	s.Equal("Italy", board["Trieste"].Owner)
+	var players []PlayerSummary
+	s.inspectQuery(`{"query": "players"}`, &players)
+	bases := map[string]int{}
+	for _, player := range players {
+		bases[player.Name] = player.Bases
+	}
+	s.Equal(2, bases["Austria"])
+	s.Equal(4, bases["Italy"])
+	s.Equal(3, bases["England"])
+}
+
+func (s *MyApplicationSuite) TestCivilDisorder() {
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"players": 1}}`))
+	s.ErrorContains(result.Err, "variant classic is played by 2 to 7 players")
+	result = s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"players": 3, "unassigned": "bots"}}`))
+	s.ErrorContains(result.Err, "invalid unassigned powers rule: bots")
+
+	result = s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"players": 3}}`))
+	s.Nil(result.Err)
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	for _, player := range []common.Address{Austria, England, France} {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+
+	var game Game
+	s.inspectQuery(`{"query": "game", "args": {"game": 1}}`, &game)
+	s.Equal(GameRunning, game.Status)
+	s.Equal(CivilDisorder, game.Unassigned)
+
+	var players []PlayerSummary
+	s.inspectQuery(`{"query": "players", "args": {"game": 1}}`, &players)
+	s.Len(players, 7)
+	s.Equal(common.Address{}, players[3].Player)
+
+	// powers in civil disorder hold and never hold the phase up
+	ready := []byte(`{"kind": "ReadyOrders", "gameID": 1, "payload": ""}`)
+	for _, player := range []common.Address{Austria, England, France} {
+		s.Nil(s.tester.Advance(player, ready).Err)
+	}
+	var phase PhaseInfo
+	s.inspectQuery(`{"query": "phase", "args": {"game": 1}}`, &phase)
+	s.Equal("F1901M", phase.Label)
+
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"game": 1, "power": "Germany"}}`, &units)
+	s.Equal("Berlin", units[0].Position)
+	s.Equal("Germany", units[0].Power)
+}
+
+func (s *MyApplicationSuite) TestPowerDoubling() {
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"players": 3, "unassigned": "double"}}`))
+	s.Nil(result.Err)
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	for _, player := range []common.Address{Austria, England, France} {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+
+	var players []PlayerSummary
+	s.inspectQuery(`{"query": "players", "args": {"game": 1}}`, &players)
+	owners := map[string]common.Address{}
+	for _, player := range players {
+		owners[player.Name] = player.Player
+	}
+	s.Equal(map[string]common.Address{
+		"Austria": Austria, "England": England, "France": France,
+		"Germany": Austria, "Italy": England, "Russia": France, "Turkey": Austria,
+	}, owners)
+
+	// orders are checked against the power the unit belongs to
+	move := []byte(`{"kind": "MoveArmy", "gameID": 1, "payload": {"UnitID": 10, "OrderType": "move", "OrderOwner": "Germany", "ToRegion": "Prussia", "FromRegion": "Berlin"}}`)
+	result = s.tester.Advance(England, move)
+	s.ErrorContains(result.Err, "can't move another player's army")
+	s.Nil(s.tester.Advance(Austria, move).Err)
+
+	// one ready covers every power of the player
+	ready := []byte(`{"kind": "ReadyOrders", "gameID": 1, "payload": ""}`)
+	for _, player := range []common.Address{Austria, England, France} {
+		s.Nil(s.tester.Advance(player, ready).Err)
+	}
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"game": 1, "power": "Germany"}}`, &units)
+	s.Equal("Prussia", units[0].Position)
+	s.Equal(Austria, units[0].Owner)
+}
+
+func (s *MyApplicationSuite) TestFogOfWar() {
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"fogOfWar": true}}`))
+	s.Nil(result.Err)
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	players := []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey}
+	for _, player := range players {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+
+	var view GameState
+	s.inspectQuery(fmt.Sprintf(`{"query": "state", "args": {"game": 1, "player": "%v"}}`, England.Hex()), &view)
+	s.Len(view.Units, 3)
+	s.Contains(view.Units, 4)
+	s.NotContains(view.Units, 1)
+	s.False(view.Board["Vienna"].Occupied)
+	s.True(view.Board["London"].Occupied)
+
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"game": 1}}`, &units)
+	s.Empty(units)
+
+	inspect := s.tester.Inspect([]byte(fmt.Sprintf(`{"query": "legalOrders", "args": {"game": 1, "unit": 1, "player": "%v"}}`, England.Hex())))
+	s.ErrorContains(inspect.Err, "unit not found")
+
+	// the fleet in Brest could move into the English Channel but England can't see it
+	var legal []Orders
+	s.inspectQuery(fmt.Sprintf(`{"query": "legalOrders", "args": {"game": 1, "unit": 4, "player": "%v"}}`, England.Hex()), &legal)
+	supported := map[string]bool{}
+	for _, order := range legal {
+		if order.Ordertype == "support move" {
+			supported[order.FromRegion] = true
+		}
+	}
+	s.True(supported["Edinburgh"])
+	s.False(supported["Brest"])
+
+	move := `{"kind": "MoveArmy", "gameID": 1, "payload" : {"UnitID": %v, "OrderType": "move", "ToRegion": "%v", "FromRegion": "%v"}}`
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(move, 4, "English Channel", "London"))).Err)
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(move, 1, "Tyrolia", "Vienna"))).Err)
+	ready := []byte(`{"kind": "ReadyOrders", "gameID": 1, "payload": ""}`)
+	for _, player := range players {
+		result = s.tester.Advance(player, ready)
+		s.Nil(result.Err)
+	}
+
+	// the public notice only announces the phase
+	s.Len(result.Notices, 1)
+	notice := s.phaseResultNotice(result)
+	s.Equal("S1901M", notice.Phase)
+	s.Empty(notice.Orders)
+
+	// every player's history is filtered for them
+	orders := func(player common.Address) map[int]bool {
+		var record PhaseRecord
+		s.inspectQuery(fmt.Sprintf(`{"query": "history", "args": {"game": 1, "phase": "S1901M", "player": "%v"}}`, player.Hex()), &record)
+		s.Equal(player, *record.Player)
+		ids := map[int]bool{}
+		for _, order := range record.Orders {
+			ids[order.Order.UnitID] = true
+		}
+		return ids
+	}
+	s.True(orders(Austria)[1])
+	s.False(orders(England)[1])
+	s.True(orders(England)[4])
+	// the English Channel borders Brest
+	s.True(orders(England)[8])
+
+	var record PhaseRecord
+	s.inspectQuery(fmt.Sprintf(`{"query": "history", "args": {"game": 1, "phase": "S1901M", "player": "%v"}}`, England.Hex()), &record)
+	for _, position := range record.Before {
+		s.NotEqual("Austria", position.Power)
+	}
+	inspect = s.tester.Inspect([]byte(`{"query": "history", "args": {"game": 1, "phase": "S1901M"}}`))
+	s.ErrorContains(inspect.Err, "player not found")
+}
+
+func (s *MyApplicationSuite) TestFogHidesBuilds() {
+	game := newGame(1, common.Address{}, big.NewInt(0), 5)
+	game.FogOfWar = true
+	game.Seats = []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey}
+	game.start()
+	game.state.Players["Austria"].Builds = []*BuildArmyInput{{Info: BuildArmyPayload{Type: "army", Position: "Vienna", Owner: "Austria"}}}
+	game.state.Players["England"].Builds = []*BuildArmyInput{{Info: BuildArmyPayload{Type: "navy", Position: "London", Owner: "England"}}}
+
+	view := game.state
+	view.Units = make(map[int]*Unit, len(game.state.Units))
+	for id, unit := range game.state.Units {
+		view.Units[id] = unit
+	}
+	view.Players = make(map[string]*Team, len(game.state.Players))
+	for power, team := range game.state.Players {
+		t := *team
+		view.Players[power] = &t
+	}
+	game.fogView(&view, []string{"England"})
+	s.Empty(view.Players["Austria"].Builds)
+	s.Len(view.Players["England"].Builds, 1)
+}
+
+func (s *MyApplicationSuite) TestChaos() {
+	variant, err := variantDefinition("chaos")
+	s.Nil(err)
+	s.Len(variant.powers(), 34)
+
+	players := make([]common.Address, 34)
+	for i := range players {
+		players[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"variant": "chaos"}}`))
+	s.Nil(result.Err)
+	join := []byte(`{"kind": "JoinGame", "gameID": 1}`)
+	for _, player := range players {
+		s.Nil(s.tester.Advance(player, join).Err)
+	}
+
+	var summary []PlayerSummary
+	s.inspectQuery(`{"query": "players", "args": {"game": 1}}`, &summary)
+	s.Len(summary, 34)
+	for _, player := range summary {
+		s.Equal(1, player.Units)
+		s.Equal(1, player.Bases)
+	}
+	var board map[string]*Region
+	s.inspectQuery(`{"query": "board", "args": {"game": 1}}`, &board)
+	s.Equal("Belgium", board["Belgium"].Owner)
+	s.Equal("Belgium", board["Belgium"].Home)
+	s.Equal("Neutral", board["Burgundy"].Owner)
+
+	// Paris takes Brest as its army leaves, then leaves it empty
+	move := `{"kind": "MoveArmy", "gameID": 1, "payload" : {"UnitID": %v, "OrderType": "move", "OrderOwner": "%v", "ToRegion": "%v", "FromRegion": "%v"}}`
+	ready := func() {
+		for _, player := range players {
+			s.Require().Nil(s.tester.Advance(player, []byte(`{"kind": "ReadyOrders", "gameID": 1, "payload": ""}`)).Err)
+		}
+	}
+	paris, brest := players[0], players[3]
+	s.Nil(s.tester.Advance(paris, []byte(fmt.Sprintf(move, 1, "Paris", "Brest", "Paris"))).Err)
+	s.Nil(s.tester.Advance(brest, []byte(fmt.Sprintf(move, 4, "Brest", "Picardy", "Brest"))).Err)
+	ready()
+	ready()
+
+	// the winter adjustment counts the centers again
+	bases := func() map[string]int {
+		s.inspectQuery(`{"query": "players", "args": {"game": 1}}`, &summary)
+		bases := map[string]int{}
+		for _, player := range summary {
+			bases[player.Name] = player.Bases
+		}
+		return bases
+	}
+	s.Equal(2, bases()["Paris"])
+	s.Equal(0, bases()["Brest"])
+	ready()
+	s.Nil(s.tester.Advance(paris, []byte(fmt.Sprintf(move, 1, "Paris", "Gascony", "Brest"))).Err)
+	ready()
+	ready()
+
+	// builds are allowed in any center owned, not only the home one
+	build := `{"kind": "BuildArmy", "gameID": 1, "payload" : {"Type": "navy", "Position": "Brest", "Owner": "Paris"}}`
+	s.Nil(s.tester.Advance(paris, []byte(build)).Err)
+
+	broken := &Variant{Name: "broken", Map: DefaultMap, Powers: []string{"Paris"}, Centers: map[string]string{"Burgundy": "Paris"}, VictoryCenters: 18, BuildRule: OwnedBuilds}
+	s.ErrorContains(broken.Validate(), "Burgundy is not a supply center of map standard")
+}
+
+func (s *MyApplicationSuite) createFullGame(payload string) int {
+	result := s.tester.Advance(Austria, []byte(fmt.Sprintf(`{"kind": "CreateGame", "payload": %v}`, payload)))
+	s.Require().Nil(result.Err)
+	var game Game
+	s.Nil(json.Unmarshal(result.Reports[0].Payload, &game))
+	join := []byte(fmt.Sprintf(`{"kind": "JoinGame", "gameID": %v}`, game.ID))
+	for _, player := range []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey} {
+		s.Require().Nil(s.tester.Advance(player, join).Err)
+	}
+	return game.ID
+}
+
+func (s *MyApplicationSuite) TestPressModes() {
+	result := s.tester.Advance(Austria, []byte(`{"kind": "CreateGame", "payload": {"press": "carrier pigeon"}}`))
+	s.ErrorContains(result.Err, "invalid press mode: carrier pigeon")
+
+	send := `{"kind": "SendMessage", "gameID": %v, "payload": {"to": %v, "text": "%v"}}`
+	full := s.createFullGame(`{}`)
+	keys := s.registerKeys(full, map[string]common.Address{"France": France})
+	s.Nil(s.sendEncrypted(full, England, keys, "channel stays empty?").Err)
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(send, full, `[]`, "peace in our time"))).Err)
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, full, `["England"]`, "hello me")))
+	s.ErrorContains(result.Err, "can't send a message to yourself")
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, full, `["Atlantis"]`, "hello")))
+	s.ErrorContains(result.Err, "power not found: Atlantis")
+
+	var messages []*Message
+	s.inspectQuery(fmt.Sprintf(`{"query": "messages", "args": {"game": %v, "player": "%v"}}`, full, France.Hex()), &messages)
+	s.Len(messages, 2)
+	s.NotZero(messages[0].Timestamp)
+	s.Equal("England", messages[0].From)
+	s.Equal([]string{"France"}, messages[0].To)
+	s.Equal("S1901M", messages[0].Phase)
+	text, err := decryptMessage(keys["France"], messages[0].Ciphertexts["France"])
+	s.Nil(err)
+	s.Equal("channel stays empty?", text)
+	s.inspectQuery(fmt.Sprintf(`{"query": "messages", "args": {"game": %v, "player": "%v"}}`, full, Germany.Hex()), &messages)
+	s.Len(messages, 1)
+	s.Empty(messages[0].To)
+	// messages are public inputs, without a player every one is listed
+	s.inspectQuery(fmt.Sprintf(`{"query": "messages", "args": {"game": %v}}`, full), &messages)
+	s.Len(messages, 2)
+
+	public := s.createFullGame(`{"press": "public"}`)
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, public, `["France"]`, "psst")))
+	s.ErrorContains(result.Err, "only public press is allowed in this game")
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(send, public, `[]`, "hello everyone"))).Err)
+
+	gunboat := s.createFullGame(`{"press": "gunboat"}`)
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, gunboat, `[]`, "hello everyone")))
+	s.ErrorContains(result.Err, "press is disabled in gunboat games")
+
+	// queries hide every address until the game ends, even the one asked for
+	var players []PlayerSummary
+	s.inspectQuery(fmt.Sprintf(`{"query": "players", "args": {"game": %v, "player": "%v"}}`, gunboat, England.Hex()), &players)
+	s.Len(players, 7)
+	for _, player := range players {
+		s.Equal(common.Address{}, player.Player)
+		s.True(player.Hidden)
+	}
+	// asking by address would tell which powers it plays, views are asked for by power instead
+	for _, query := range []string{"state", "board", "units", "builds", "legalOrders"} {
+		inspect := s.tester.Inspect([]byte(fmt.Sprintf(`{"query": "%v", "args": {"game": %v, "player": "%v", "unit": 1}}`, query, gunboat, England.Hex())))
+		s.ErrorContains(inspect.Err, "anonymous games are viewed by power, not by player")
+	}
+	var view GameState
+	s.inspectQuery(fmt.Sprintf(`{"query": "state", "args": {"game": %v, "power": "France"}}`, gunboat), &view)
+	for _, team := range view.Players {
+		s.Equal(common.Address{}, team.Player)
+		s.True(team.Hidden)
+	}
+	for _, unit := range view.Units {
+		s.Equal(common.Address{}, unit.Owner)
+	}
+	var builds []*BuildArmyInput
+	s.inspectQuery(fmt.Sprintf(`{"query": "builds", "args": {"game": %v, "power": "France"}}`, gunboat), &builds)
+	s.Empty(builds)
+
+	// reports are public, the sender isn't shown their own power either
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(`{"kind": "ReadyOrders", "gameID": %v, "payload": ""}`, gunboat)))
+	s.Nil(result.Err)
+	s.Nil(json.Unmarshal(result.Reports[0].Payload, &view))
+	for _, team := range view.Players {
+		s.Equal(common.Address{}, team.Player)
+		s.True(team.Hidden)
+	}
+	for _, unit := range view.Units {
+		s.Equal(common.Address{}, unit.Owner)
+	}
+
+	var game Game
+	s.inspectQuery(fmt.Sprintf(`{"query": "game", "args": {"game": %v}}`, gunboat), &game)
+	s.Equal(GunboatPress, game.Press)
+	s.Equal([]common.Address{Austria, England, France, Germany, Italy, Russia, Turkey}, game.Seats)
+}
+
+func (s *MyApplicationSuite) TestDrawPowers() {
+	players := []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey}
+	game := newGame(1, common.Address{}, big.NewInt(0), 5)
+	game.Press = GunboatPress
+	game.Seats = append([]common.Address{}, players...)
+	game.drawPowers(rollmelette.Metadata{InputIndex: 7, BlockNumber: 100, BlockTimestamp: 1700000000})
+	s.ElementsMatch(players, game.Seats)
+	s.NotEqual(players, game.Seats)
+
+	// every node draws the same powers
+	again := newGame(1, common.Address{}, big.NewInt(0), 5)
+	again.Seats = append([]common.Address{}, players...)
+	again.drawPowers(rollmelette.Metadata{InputIndex: 7, BlockNumber: 100, BlockTimestamp: 1700000000})
+	s.Equal(game.Seats, again.Seats)
+}
+
+func (s *MyApplicationSuite) TestMessaging() {
+	send := `{"kind": "SendMessage", "payload": {"to": %v, "text": "%v"}}`
+	keys := s.registerKeys(0, map[string]common.Address{"France": France, "Germany": Germany})
+	s.Nil(s.sendEncrypted(0, England, keys, "a western triple?").Err)
+	result := s.tester.Advance(England, []byte(fmt.Sprintf(send, `["France"]`, "a western triple?")))
+	s.ErrorContains(result.Err, "private messages must be encrypted")
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, `["France", "France"]`, "twice")))
+	s.ErrorContains(result.Err, "power listed twice: France")
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, `[]`, strings.Repeat("a", MaxMessageLength+1))))
+	s.ErrorContains(result.Err, fmt.Sprintf("message is longer than %v bytes", MaxMessageLength))
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, `[]`, "")))
+	s.ErrorContains(result.Err, "can't send an empty message")
+
+	var messages []*Message
+	for _, player := range []common.Address{England, France, Germany} {
+		s.inspectQuery(fmt.Sprintf(`{"query": "messages", "args": {"player": "%v"}}`, player.Hex()), &messages)
+		s.Len(messages, 1)
+	}
+	s.inspectQuery(fmt.Sprintf(`{"query": "messages", "args": {"player": "%v"}}`, Italy.Hex()), &messages)
+	s.Empty(messages)
+
+	// the limit is per phase, it starts over once the phase is adjudicated
+	for i := 1; i < MaxMessagesPerPhase; i++ {
+		s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(send, `[]`, "spam"))).Err)
+	}
+	result = s.tester.Advance(England, []byte(fmt.Sprintf(send, `[]`, "spam")))
+	s.ErrorContains(result.Err, fmt.Sprintf("can't send more than %v messages per phase", MaxMessagesPerPhase))
+	_, err := s.PassTurn()
+	s.Nil(err)
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(send, `[]`, "autumn"))).Err)
+
+	s.inspectQuery(fmt.Sprintf(`{"query": "messages", "args": {"player": "%v", "phase": "F1901M"}}`, Italy.Hex()), &messages)
+	s.Len(messages, 1)
+	s.Equal("autumn", messages[0].Text)
+}
+
+// encryptMessage encrypts the message to the recipient's X25519 key the way a client would: an
+// ephemeral key agreement whose hashed secret keys AES-GCM, the ciphertext starts with the
+// ephemeral public key and the nonce
+func encryptMessage(recipient *ecdh.PublicKey, message string) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := append(ephemeral.PublicKey().Bytes(), nonce...)
+	return aead.Seal(ciphertext, nonce, []byte(message), nil), nil
+}
+
+// decryptMessage reverses encryptMessage with the recipient's private key
+func decryptMessage(recipient *ecdh.PrivateKey, ciphertext []byte) (string, error) {
+	if len(ciphertext) < 32 {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ciphertext[:32])
+	if err != nil {
+		return "", err
+	}
+	secret, err := recipient.ECDH(ephemeral)
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := ciphertext[32 : 32+aead.NonceSize()]
+	message, err := aead.Open(nil, nonce, ciphertext[32+aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// registerKeys registers a new encryption key for each power, played by the given players
+func (s *MyApplicationSuite) registerKeys(game int, players map[string]common.Address) map[string]*ecdh.PrivateKey {
+	register := `{"kind": "RegisterKey", "gameID": %v, "payload": {"key": "%v"}}`
+	keys := map[string]*ecdh.PrivateKey{}
+	for power, player := range players {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		s.Require().Nil(err)
+		keys[power] = key
+		encoded := base64.StdEncoding.EncodeToString(key.PublicKey().Bytes())
+		s.Require().Nil(s.tester.Advance(player, []byte(fmt.Sprintf(register, game, encoded))).Err)
+	}
+	return keys
+}
+
+// sendEncrypted sends the message to every power with a key, encrypted to each of them
+func (s *MyApplicationSuite) sendEncrypted(
+	game int,
+	from common.Address,
+	keys map[string]*ecdh.PrivateKey,
+	message string,
+) rollmelette.TestAdvanceResult {
+	to := sortedKeys(keys)
+	ciphertexts := map[string][]byte{}
+	for _, power := range to {
+		ciphertext, err := encryptMessage(keys[power].PublicKey(), message)
+		s.Require().Nil(err)
+		ciphertexts[power] = ciphertext
+	}
+	payload, err := json.Marshal(SendMessagePayload{To: to, Ciphertexts: ciphertexts})
+	s.Require().Nil(err)
+	return s.tester.Advance(from, []byte(fmt.Sprintf(`{"kind": "SendMessage", "gameID": %v, "payload": %s}`, game, payload)))
+}
+
+func (s *MyApplicationSuite) TestEncryptedPress() {
+	register := `{"kind": "RegisterKey", "payload": {"key": "%v"}}`
+	result := s.tester.Advance(France, []byte(fmt.Sprintf(register, base64.StdEncoding.EncodeToString([]byte("short")))))
+	s.ErrorContains(result.Err, "invalid encryption key")
+
+	keys := map[string]*ecdh.PrivateKey{}
+	for power, player := range map[string]common.Address{"France": France, "Germany": Germany} {
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		s.Require().Nil(err)
+		keys[power] = key
+		encoded := base64.StdEncoding.EncodeToString(key.PublicKey().Bytes())
+		s.Nil(s.tester.Advance(player, []byte(fmt.Sprintf(register, encoded))).Err)
+	}
+
+	ciphertexts := map[string][]byte{}
+	for _, power := range []string{"France", "Germany"} {
+		ciphertext, err := encryptMessage(keys[power].PublicKey(), "let's carve up Belgium")
+		s.Require().Nil(err)
+		ciphertexts[power] = ciphertext
+	}
+	send := func(to []string, text string, ciphertexts map[string][]byte) rollmelette.TestAdvanceResult {
+		payload, err := json.Marshal(SendMessagePayload{To: to, Text: text, Ciphertexts: ciphertexts})
+		s.Require().Nil(err)
+		return s.tester.Advance(England, []byte(fmt.Sprintf(`{"kind": "SendMessage", "payload": %s}`, payload)))
+	}
+	s.ErrorContains(send(nil, "", ciphertexts).Err, "encrypted messages need recipients")
+	s.ErrorContains(send([]string{"France", "Germany"}, "hi", ciphertexts).Err, "encrypted messages can't carry a plain text")
+	s.ErrorContains(send([]string{"France", "Italy"}, "", ciphertexts).Err, "missing ciphertext for Italy")
+	italy := map[string][]byte{"Italy": ciphertexts["France"]}
+	s.ErrorContains(send([]string{"Italy"}, "", italy).Err, "Italy has no encryption key registered")
+	s.Nil(send([]string{"France", "Germany"}, "", ciphertexts).Err)
+
+	var state GameState
+	s.inspectQuery(`{"query": "state"}`, &state)
+	s.Equal(keys["France"].PublicKey().Bytes(), state.Players["France"].EncryptionKey)
+
+	for power, player := range map[string]common.Address{"France": France, "Germany": Germany} {
+		var messages []*Message
+		s.inspectQuery(fmt.Sprintf(`{"query": "messages", "args": {"player": "%v"}}`, player.Hex()), &messages)
+		s.Require().Len(messages, 1)
+		s.Empty(messages[0].Text)
+		message, err := decryptMessage(keys[power], messages[0].Ciphertexts[power])
+		s.Nil(err)
+		s.Equal("let's carve up Belgium", message)
+	}
+	_, err := decryptMessage(keys["Germany"], ciphertexts["France"])
+	s.NotNil(err)
+}
+
+func (s *MyApplicationSuite) TestTreaties() {
+	propose := `{"kind": "ProposeTreaty", "gameID": %v, "payload": %v}`
+	accept := `{"kind": "AcceptTreaty", "gameID": %v, "payload": {"treatyID": %v}}`
+	result := s.tester.Advance(Austria, []byte(fmt.Sprintf(propose, 0, `{"to": ["Russia"], "kind": "nonAggression", "years": 1}`)))
+	s.ErrorContains(result.Err, "treaties are not enabled in this game")
+
+	game := s.createFullGame(`{"treaties": true}`)
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(propose, game, `{"to": ["Russia"], "kind": "alliance", "years": 1}`)))
+	s.ErrorContains(result.Err, "invalid treaty kind: alliance")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(propose, game, `{"to": ["Russia"], "kind": "dmz", "years": 1}`)))
+	s.ErrorContains(result.Err, "demilitarized zones need regions")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(propose, game, `{"to": ["Russia"], "kind": "dmz", "regions": ["Galicia"], "years": 0}`)))
+	s.ErrorContains(result.Err, "treaties last at least a year")
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(propose, game, `{"to": ["Russia"], "kind": "dmz", "regions": ["Galicia"], "years": 1}`))).Err)
+	s.Nil(s.tester.Advance(Italy, []byte(fmt.Sprintf(propose, game, `{"to": ["Austria"], "kind": "nonAggression", "years": 2}`))).Err)
+
+	// the treaty doesn't bind until every party signed it, the orders breaking it then turn into holds
+	move := `{"kind": "MoveArmy", "gameID": %v, "payload" : {"UnitID": %v, "OrderType": "move", "OrderOwner": "%v", "ToRegion": "%v", "FromRegion": "%v"}}`
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(move, game, 1, "Austria", "Galicia", "Vienna"))).Err)
+	var legal []Orders
+	s.inspectQuery(fmt.Sprintf(`{"query": "legalOrders", "args": {"game": %v, "unit": 1}}`, game), &legal)
+	s.Contains(legal, Orders{UnitID: 1, Ordertype: "move", OrderOwner: "Austria", ToRegion: "Galicia", FromRegion: "Vienna"})
+	result = s.tester.Advance(Turkey, []byte(fmt.Sprintf(accept, game, 1)))
+	s.ErrorContains(result.Err, "Turkey is not a party of the treaty")
+	s.Nil(s.tester.Advance(Russia, []byte(fmt.Sprintf(accept, game, 1))).Err)
+	result = s.tester.Advance(Russia, []byte(fmt.Sprintf(accept, game, 1)))
+	s.ErrorContains(result.Err, "treaty is not open to signatures")
+	var view GameState
+	s.inspectQuery(fmt.Sprintf(`{"query": "state", "args": {"game": %v, "player": "%v"}}`, game, Austria.Hex()), &view)
+	s.Equal("hold", view.Units[1].CurrentOrder.Ordertype)
+	s.inspectQuery(fmt.Sprintf(`{"query": "legalOrders", "args": {"game": %v, "unit": 1}}`, game), &legal)
+	s.NotEmpty(legal)
+	for _, order := range legal {
+		s.NotEqual("Galicia", order.ToRegion)
+	}
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(move, game, 1, "Austria", "Galicia", "Vienna")))
+	s.ErrorContains(result.Err, "order breaks treaty 1: Galicia is demilitarized")
+	result = s.tester.Advance(Russia, []byte(fmt.Sprintf(move, game, 18, "Russia", "Galicia", "Warsaw")))
+	s.ErrorContains(result.Err, "order breaks treaty 1: Galicia is demilitarized")
+
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(accept, game, 2))).Err)
+	result = s.tester.Advance(Italy, []byte(fmt.Sprintf(move, game, 14, "Italy", "Trieste", "Venice")))
+	s.ErrorContains(result.Err, "order breaks treaty 2: Trieste is a home center of Austria")
+
+	withdraw := `{"kind": "WithdrawTreaty", "gameID": %v, "payload": {"treatyID": %v}}`
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(propose, game, `{"to": ["France"], "kind": "nonAggression", "years": 1}`))).Err)
+	result = s.tester.Advance(France, []byte(fmt.Sprintf(withdraw, game, 3)))
+	s.ErrorContains(result.Err, "only the proposer can withdraw the treaty")
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(withdraw, game, 3))).Err)
+	result = s.tester.Advance(France, []byte(fmt.Sprintf(accept, game, 3)))
+	s.ErrorContains(result.Err, "treaty is not open to signatures")
+
+	var treaties []*Treaty
+	s.inspectQuery(fmt.Sprintf(`{"query": "treaties", "args": {"game": %v, "power": "Austria"}}`, game), &treaties)
+	s.Len(treaties, 2)
+	s.Equal(Treaty{ID: 1, Status: TreatyActive, Kind: DMZ, Proposer: "Austria", Parties: []string{"Austria", "Russia"}, Accepted: []string{"Austria", "Russia"}, Regions: []string{"Galicia"}, Years: 1, Expires: 1902}, *treaties[0])
+
+	// a year later the one year treaty is over and the two year one still binds
+	ready := []byte(fmt.Sprintf(`{"kind": "ReadyOrders", "gameID": %v, "payload": ""}`, game))
+	for i := 0; i < 3; i++ {
+		for _, player := range []common.Address{Austria, England, France, Germany, Italy, Russia, Turkey} {
+			s.Require().Nil(s.tester.Advance(player, ready).Err)
+		}
+	}
+	s.inspectQuery(fmt.Sprintf(`{"query": "treaties", "args": {"game": %v}}`, game), &treaties)
+	s.Len(treaties, 3)
+	s.Equal(TreatyExpired, treaties[0].Status)
+	s.Equal(TreatyActive, treaties[1].Status)
+	s.Equal(TreatyWithdrawn, treaties[2].Status)
+	s.Nil(s.tester.Advance(Russia, []byte(fmt.Sprintf(move, game, 18, "Russia", "Galicia", "Warsaw"))).Err)
+}
+
+func (s *MyApplicationSuite) TestSubmitOrders() {
+	s.Nil(s.tester.Advance(Austria, []byte(`{"kind": "MoveArmy", "payload" : {"UnitID": 3, "OrderType": "move", "OrderOwner": "Austria", "ToRegion": "Adriatic Sea", "FromRegion": "Trieste"}}`)).Err)
+
+	submit := `{"kind": "SubmitOrders", "payload": {"orders": [%v], "ready": %v}}`
+	vienna := `{"UnitID": 1, "OrderType": "move", "OrderOwner": "Austria", "ToRegion": "Galicia", "FromRegion": "Vienna"}`
+	budapest := `{"UnitID": 2, "OrderType": "move", "OrderOwner": "Austria", "ToRegion": "Serbia", "FromRegion": "Budapest"}`
+	result := s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+`, {"UnitID": 2, "OrderType": "move", "OrderOwner": "Austria", "ToRegion": "Warsaw", "FromRegion": "Budapest"}`, false)))
+	s.ErrorContains(result.Err, "invalid order for unit 2")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+", "+vienna, false)))
+	s.ErrorContains(result.Err, "unit 1 ordered twice")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, `{"UnitID": 4, "OrderType": "move", "OrderOwner": "England", "ToRegion": "Wales", "FromRegion": "London"}`, false)))
+	s.ErrorContains(result.Err, "can't move another player's army")
+	result = s.tester.Advance(Austria, []byte(`{"kind": "SubmitOrders", "payload": {"builds": [{"type": "army", "Position": "Vienna"}]}}`))
+	s.ErrorContains(result.Err, "cant build an army outside build phase")
+
+	// rejected batches leave the earlier orders alone
+	var view GameState
+	query := fmt.Sprintf(`{"query": "state", "args": {"player": "%v"}}`, Austria.Hex())
+	s.inspectQuery(query, &view)
+	s.Equal("move", view.Units[3].CurrentOrder.Ordertype)
+	s.Equal("hold", view.Units[1].CurrentOrder.Ordertype)
+
+	// an accepted one replaces them
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+", "+budapest, true))).Err)
+	s.inspectQuery(query, &view)
+	s.Equal("hold", view.Units[3].CurrentOrder.Ordertype)
+	s.Equal("Galicia", view.Units[1].CurrentOrder.ToRegion)
+	s.Equal("Serbia", view.Units[2].CurrentOrder.ToRegion)
+	s.True(view.Players["Austria"].Ready)
+
+	for _, player := range []common.Address{England, France, Germany, Italy, Russia, Turkey} {
+		s.Nil(s.tester.Advance(player, PassTurnPayloadSetup).Err)
+	}
+	s.inspectQuery(query, &view)
+	s.Equal("Galicia", view.Units[1].Position)
+	s.Equal("Serbia", view.Units[2].Position)
+	s.Equal("Trieste", view.Units[3].Position)
+}
+
+func (s *MyApplicationSuite) TestSubmitBuilds() {
+	s.Nil(s.tester.Advance(Austria, []byte(`{"kind": "SubmitOrders", "payload": {"notation": ["A VIE - GAL", "A BUD - SER"]}}`)).Err)
+	s.passTurnResult()
+	s.passTurnResult()
+
+	submit := `{"kind": "SubmitOrders", "payload": {"builds": [%v]}}`
+	vienna := `{"type": "army", "Position": "Vienna"}`
+	budapest := `{"type": "army", "Position": "Budapest"}`
+	trieste := `{"Position": "Trieste", "delete": 3}`
+	result := s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+`, {"type": "army", "Position": "vie"}`)))
+	s.ErrorContains(result.Err, "Vienna ordered twice")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+", "+budapest)))
+	s.ErrorContains(result.Err, "cant build another army without extra supply centers")
+
+	// only the power's own units standing in the center can be disbanded, once each
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+", "+budapest+`, {"Position": "Trieste", "delete": 999}`)))
+	s.ErrorContains(result.Err, "no unit 999 of Austria in Trieste")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+", "+budapest+`, {"Position": "Trieste", "delete": 4}`)))
+	s.ErrorContains(result.Err, "no unit 4 of Austria in Trieste")
+	result = s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+", "+trieste+", "+trieste)))
+	s.ErrorContains(result.Err, "unit 3 ordered twice")
+	result = s.tester.Advance(Austria, []byte(`{"kind": "BuildArmy", "payload" : {"Position": "London", "Owner": "Austria", "Delete": 4}}`))
+	s.ErrorContains(result.Err, "no unit 4 of Austria in London")
+
+	// the unit deleted frees a center for another build
+	s.Nil(s.tester.Advance(Austria, []byte(fmt.Sprintf(submit, vienna+", "+budapest+", "+trieste))).Err)
+	s.passTurnResult()
+	var units []*Unit
+	s.inspectQuery(`{"query": "units", "args": {"power": "Austria"}}`, &units)
+	positions := []string{}
+	for _, unit := range units {
+		positions = append(positions, unit.Position)
+	}
+	s.ElementsMatch([]string{"Galicia", "Serbia", "Vienna", "Budapest"}, positions)
+}
+
+func (s *MyApplicationSuite) TestOrderNotation() {
+	move := `{"kind": "MoveArmy", "payload": "%v"}`
+	for player, orders := range map[common.Address][]string{
+		England: {"F LON - NTH", "A LVP - YOR", "f edi - nwg"},
+		France:  {"A PAR - BUR"},
+		Germany: {"A BER - SIL", "A MUN S A BER - SIL", "F KIE H"},
+		Russia:  {"F STP/SC - BOT"},
+		Turkey:  {"F ANK S A CON"},
+	} {
+		for _, order := range orders {
+			s.Nil(s.tester.Advance(player, []byte(fmt.Sprintf(move, order))).Err, order)
+		}
+	}
+	result := s.tester.Advance(England, []byte(fmt.Sprintf(move, "A PAR - BUR")))
+	s.ErrorContains(result.Err, "can't move another player's army")
+	result = s.tester.Advance(France, []byte(fmt.Sprintf(move, "F PAR - BUR")))
+	s.ErrorContains(result.Err, "no navy in Paris")
+	result = s.tester.Advance(France, []byte(fmt.Sprintf(move, "A XYZ - BUR")))
+	s.ErrorContains(result.Err, "region not found: XYZ")
+	result = s.tester.Advance(France, []byte(fmt.Sprintf(move, "A PAR BUR")))
+	s.ErrorContains(result.Err, "invalid order notation: A PAR BUR")
+
+	var view GameState
+	s.inspectQuery(fmt.Sprintf(`{"query": "state", "args": {"player": "%v"}}`, Russia.Hex()), &view)
+	s.Equal(Orders{UnitID: 17, Ordertype: "move", OrderOwner: "Russia", ToRegion: "Gulf of Bothnia", FromRegion: "St Petersburg", FromSubRegion: "SC"}, view.Units[17].CurrentOrder)
+
+	notations := func(phase PhaseResult) []string {
+		var notations []string
+		for _, order := range phase.Orders {
+			notations = append(notations, order.Notation)
+		}
+		return notations
+	}
+	phase := s.phaseResultNotice(s.passTurnResult())
+	s.Subset(notations(phase), []string{"A VIE H", "F LON - NTH", "A PAR - BUR", "A MUN S A BER - SIL", "F STP/SC - BOT", "F ANK S A CON"})
+
+	// armies moving to a region they don't border go by convoy
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(move, "F NTH C A YOR - NWY"))).Err)
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(move, "A YOR - NWY"))).Err)
+	s.Nil(s.tester.Advance(Russia, []byte(`{"kind": "SubmitOrders", "payload": {"notation": ["A MOS - STP", "F BOT - SWE"]}}`)).Err)
+	phase = s.phaseResultNotice(s.passTurnResult())
+	s.Subset(notations(phase), []string{"F NTH C A YOR - NWY", "A YOR - NWY VIA", "A MOS - STP", "F BOT - SWE"})
+	s.inspectQuery(fmt.Sprintf(`{"query": "state", "args": {"player": "%v"}}`, England.Hex()), &view)
+	s.Equal("Norway", view.Units[5].Position)
+}
+
+func (s *MyApplicationSuite) TestRegionNames() {
+	move := `{"kind": "MoveArmy", "payload" : {"UnitID": %v, "OrderType": "%v", "FromRegion": "%v", "FromSubRegion": "%v", "ToRegion": "%v"}}`
+	s.Nil(s.tester.Advance(Russia, []byte(fmt.Sprintf(move, 17, "move", "st. petersburg", "sc", "gulf of bothnia"))).Err)
+	s.Nil(s.tester.Advance(England, []byte(fmt.Sprintf(move, 4, "move", "LON", "", "ech"))).Err)
+	s.Nil(s.tester.Advance(Germany, []byte(fmt.Sprintf(move, 11, "move", "Munich", "", "Ruhr"))).Err)
+
+	var view GameState
+	s.inspectQuery(fmt.Sprintf(`{"query": "state", "args": {"player": "%v"}}`, Russia.Hex()), &view)
+	s.Equal(Orders{UnitID: 17, Ordertype: "move", ToRegion: "Gulf of Bothnia", FromRegion: "St Petersburg", FromSubRegion: "SC"}, view.Units[17].CurrentOrder)
+
+	// unknown names are rejected instead of reaching the board
+	result := s.tester.Advance(France, []byte(fmt.Sprintf(move, 7, "move", "Paris", "", "Atlantis")))
+	s.ErrorContains(result.Err, "region not found: Atlantis")
+	result = s.tester.Advance(France, []byte(fmt.Sprintf(move, 7, "move", "", "", "Burgundy")))
+	s.ErrorContains(result.Err, "need to specify the region")
+	result = s.tester.Advance(Russia, []byte(fmt.Sprintf(move, 17, "move", "St Petersburg/NC", "SC", "Gulf of Bothnia")))
+	s.ErrorContains(result.Err, "conflicting coasts: NC and SC")
+	result = s.tester.Advance(France, []byte(fmt.Sprintf(move, 9, "convoy move", "Marseilles", "", "Spain")))
+	s.ErrorContains(result.Err, "no available boats to convoy")
+
+	var region Region
+	s.inspectQuery(`{"query": "region", "args": {"region": "ruhr"}}`, &region)
+	s.Equal("Ruhr", region.Name)
+	s.Equal("RUH", region.Abbreviation)
+	// the old spelling still resolves
+	s.inspectQuery(`{"query": "region", "args": {"region": "Rhur"}}`, &region)
+	s.Equal("Ruhr", region.Name)
+	inspect := s.tester.Inspect([]byte(`{"query": "region", "args": {"region": "Atlantis"}}`))
+	s.ErrorContains(inspect.Err, "region not found: Atlantis")
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// visibility is the set of regions a player can see in a fog of war game
+// The fog only filters what the application shows: orders are public inputs and adjudication is
+// deterministic, so anyone reading the inputs can work out the whole board
+type visibility map[string]bool
+
+// powersOf lists the powers the player controls
+func (a *Game) powersOf(player common.Address) []string {
+	powers := []string{}
+	for _, team := range a.state.teamsOf(player) {
+		powers = append(powers, team.Name)
+	}
+	return powers
+}
+
+// visibleRegions is what the powers see of the board: the regions where they have a unit or a
+// supply center and every region next to them
+func (a *Game) visibleRegions(powers []string) visibility {
+	var regions []string
+	for _, unit := range a.state.Units {
+		if contains(powers, unit.Power) {
+			regions = append(regions, unit.Position)
+		}
+	}
+	for name, region := range a.state.Board {
+		if region.SupplyCenter && contains(powers, region.Owner) {
+			regions = append(regions, name)
+		}
+	}
+	return a.sight(regions)
+}
+
+// sight is the given regions along with every region bordering them
+func (a *Game) sight(regions []string) visibility {
+	visible := visibility{}
+	for _, name := range regions {
+		region, ok := a.state.Board[name]
+		if !ok {
+			continue
+		}
+		visible[name] = true
+		for _, neighbor := range region.Neighbors {
+			visible[*neighbor] = true
+		}
+	}
+	return visible
+}
+
+// fogView hides from the view every unit the powers can't see, along with its orders, the
+// occupation of the regions out of sight and the builds other powers order there
+func (a *Game) fogView(view *GameState, powers []string) {
+	visible := a.visibleRegions(powers)
+	for id, unit := range view.Units {
+		if !visible[unit.Position] {
+			delete(view.Units, id)
+		}
+	}
+
+	view.Board = make(map[string]*Region, len(a.state.Board))
+	for name, region := range a.state.Board {
+		r := *region
+		if !visible[name] {
+			r.Occupied = false
+		}
+		view.Board[name] = &r
+	}
+
+	for _, team := range view.Players {
+		armies := make(map[int]string, len(team.Armies))
+		for id, region := range team.Armies {
+			if _, ok := view.Units[id]; ok {
+				armies[id] = region
+			}
+		}
+		team.Armies = armies
+
+		builds := []*BuildArmyInput{}
+		for _, build := range team.Builds {
+			if contains(powers, team.Name) || visible[build.Info.Position] {
+				builds = append(builds, build)
+			}
+		}
+		team.Builds = builds
+	}
+}
+
+// fogRecord filters the record of a phase down to what the player saw: their own powers' orders,
+// builds and dislodgements, the ones of the units in the regions visible before or after the phase
+// and the supply centers changing hands in those regions
+func (a *Game) fogRecord(record *PhaseRecord, snapshot phaseSnapshot, player common.Address) *PhaseRecord {
+	powers := a.powersOf(player)
+	var regions []string
+	for _, unit := range snapshot.units {
+		if contains(powers, unit.Power) {
+			regions = append(regions, unit.Position)
+		}
+	}
+	for name, owner := range snapshot.centers {
+		if contains(powers, owner) {
+			regions = append(regions, name)
+		}
+	}
+	visible := a.sight(regions)
+	for name := range a.visibleRegions(powers) {
+		visible[name] = true
+	}
+	seen := func(power string, region string) bool {
+		return contains(powers, power) || visible[region]
+	}
+
+	view := &PhaseRecord{
+		PhaseResult: PhaseResult{
+			Phase:         record.Phase,
+			Player:        &player,
+			Orders:        []OrderResult{},
+			Builds:        []*BuildArmyInput{},
+			Dislodged:     []Dislodgement{},
+			CenterChanges: []CenterChange{},
+		},
+		Before: []UnitPosition{},
+		After:  []UnitPosition{},
+	}
+	for _, order := range record.Orders {
+		if seen(order.Power, snapshot.units[order.Order.UnitID].Position) || visible[order.Order.ToRegion] {
+			view.Orders = append(view.Orders, order)
+		}
+	}
+	for _, build := range record.Builds {
+		if seen(build.Info.Owner, build.Info.Position) {
+			view.Builds = append(view.Builds, build)
+		}
+	}
+	for _, dislodgement := range record.Dislodged {
+		if seen(dislodgement.Power, dislodgement.Region) {
+			view.Dislodged = append(view.Dislodged, dislodgement)
+		}
+	}
+	for _, change := range record.CenterChanges {
+		if visible[change.Region] {
+			view.CenterChanges = append(view.CenterChanges, change)
+		}
+	}
+	for _, position := range record.Before {
+		if seen(position.Power, position.Position) {
+			view.Before = append(view.Before, position)
+		}
+	}
+	for _, position := range record.After {
+		if seen(position.Power, position.Position) {
+			view.After = append(view.After, position)
+		}
+	}
+	return view
+}
+
+// historyView is the record of the phase as the seat playing the powers saw it, queries aren't
+// authenticated so any seat's view can be asked for, an anonymous game leaves the address out
+func (a *Game) historyView(record *PhaseRecord, powers []string) (*PhaseRecord, error) {
+	if !a.FogOfWar {
+		return record, nil
+	}
+	if len(powers) == 0 {
+		return nil, fmt.Errorf("player not found")
+	}
+	view, ok := record.views[a.seatOf(powers[0])]
+	if !ok {
+		return nil, fmt.Errorf("player not found")
+	}
+	if a.anonymous() {
+		hidden := *view
+		hidden.Player = nil
+		return &hidden, nil
+	}
+	return view, nil
+}
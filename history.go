@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// UnitPosition is where a unit stood at some point of the game
+type UnitPosition struct {
+	UnitID      int    `json:"unitID"`
+	Type        string `json:"type"`
+	Power       string `json:"power"`
+	Position    string `json:"position"`
+	SubPosition string `json:"subPosition"`
+}
+
+// PhaseRecord is the history entry of an adjudicated phase
+// It keeps the positions before and after the phase along with every order submitted and its result
+// In a fog of war game views keeps the record as each player saw it
+type PhaseRecord struct {
+	PhaseResult
+	Before []UnitPosition `json:"before"`
+	After  []UnitPosition `json:"after"`
+
+	views map[common.Address]*PhaseRecord
+}
+
+func (a *Game) recordPhase(result PhaseResult, snapshot phaseSnapshot) *PhaseRecord {
+	before := make([]*Unit, 0, len(snapshot.units))
+	for _, unit := range snapshot.units {
+		u := unit
+		before = append(before, &u)
+	}
+	after := make([]*Unit, 0, len(a.state.Units))
+	for _, unit := range a.state.Units {
+		after = append(after, unit)
+	}
+
+	record := &PhaseRecord{
+		PhaseResult: result,
+		Before:      a.positions(before),
+		After:       a.positions(after),
+	}
+	if a.FogOfWar {
+		record.views = make(map[common.Address]*PhaseRecord, len(a.Seats))
+		for _, player := range a.Seats {
+			record.views[player] = a.fogRecord(record, snapshot, player)
+		}
+	}
+	a.state.History = append(a.state.History, record)
+	return record
+}
+
+func (a *Game) positions(units []*Unit) []UnitPosition {
+	positions := make([]UnitPosition, 0, len(units))
+	for _, unit := range units {
+		positions = append(positions, UnitPosition{
+			UnitID:      unit.ID,
+			Type:        unit.Type,
+			Power:       unit.Power,
+			Position:    unit.Position,
+			SubPosition: unit.SubPosition,
+		})
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].UnitID < positions[j].UnitID
+	})
+	return positions
+}
+
+// phaseRecord looks up the history entry of a phase by its label, e.g. S1901M
+func (a *Game) phaseRecord(label string) (*PhaseRecord, error) {
+	for _, record := range a.state.History {
+		if record.Phase == label {
+			return record, nil
+		}
+	}
+	return nil, fmt.Errorf("phase not found: %v", label)
+}
+
+// phaseLabels lists the labels of every phase already adjudicated
+func (a *Game) phaseLabels() []string {
+	labels := []string{}
+	for _, record := range a.state.History {
+		labels = append(labels, record.Phase)
+	}
+	return labels
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rollmelette/rollmelette"
+)
+
+type QueryKind string
+
+// Inspect queries accepted
+const (
+	StateQuery   QueryKind = "state"
+	SummaryQuery QueryKind = "summary"
+	BoardQuery   QueryKind = "board"
+	RegionQuery  QueryKind = "region"
+	UnitsQuery   QueryKind = "units"
+	BuildsQuery  QueryKind = "builds"
+	PhaseQuery   QueryKind = "phase"
+	PlayersQuery QueryKind = "players"
+	HistoryQuery QueryKind = "history"
+	MessageQuery QueryKind = "messages"
+	TreatyQuery  QueryKind = "treaties"
+	LegalQuery   QueryKind = "legalOrders"
+	GamesQuery   QueryKind = "games"
+	GameQuery    QueryKind = "game"
+	LeaderQuery  QueryKind = "leaderboard"
+	ProfileQuery QueryKind = "profile"
+
+	TournamentsQuery QueryKind = "tournaments"
+	TournamentQuery  QueryKind = "tournament"
+)
+
+// InspectQuery is the envelope every inspect payload must follow
+type InspectQuery struct {
+	Query QueryKind       `json:"query"`
+	Args  json.RawMessage `json:"args"`
+}
+
+// QueryArgs holds every argument a query may use, each query reads only the ones it needs
+// Game is the ID of the game queried, game 0 by default
+// Player selects whose view of the board is returned, an empty player gets the public view,
+// queries are not authenticated so a view is a filter and not a secret
+// Power filters the units by team name
+// Region is the name of the region to look up
+// Unit is the ID of the unit to look up
+// Phase is the label of an adjudicated phase, e.g. S1901M
+// Tournament is the ID of the tournament queried
+type QueryArgs struct {
+	Game       int            `json:"game"`
+	Player     common.Address `json:"player"`
+	Power      string         `json:"power"`
+	Region     string         `json:"region"`
+	Unit       int            `json:"unit"`
+	Phase      string         `json:"phase"`
+	Tournament int            `json:"tournament"`
+}
+
+type queryHandler func(a *Game, args QueryArgs) (any, error)
+
+type appQueryHandler func(a *GameApplication, args QueryArgs) (any, error)
+
+// appQueryHandlers answer queries about the application as a whole, every other query is
+// answered by the game selected in the arguments
+var appQueryHandlers = map[QueryKind]appQueryHandler{
+	GamesQuery:   queryGames,
+	GameQuery:    queryGame,
+	LeaderQuery:  queryLeaderboard,
+	ProfileQuery: queryProfile,
+
+	TournamentsQuery: queryTournaments,
+	TournamentQuery:  queryTournament,
+}
+
+var queryHandlers = map[QueryKind]queryHandler{
+	StateQuery:   queryState,
+	SummaryQuery: querySummary,
+	BoardQuery:   queryBoard,
+	RegionQuery:  queryRegion,
+	UnitsQuery:   queryUnits,
+	BuildsQuery:  queryBuilds,
+	PhaseQuery:   queryPhase,
+	PlayersQuery: queryPlayers,
+	HistoryQuery: queryHistory,
+	MessageQuery: queryMessages,
+	TreatyQuery:  queryTreaties,
+	LegalQuery:   queryLegalOrders,
+}
+
+// PlayerSummary is the public information about a player during a phase
+type PlayerSummary struct {
+	Name   string         `json:"name"`
+	Player common.Address `json:"player"`
+	Ready  bool           `json:"ready"`
+	Units  int            `json:"units"`
+	Bases  int            `json:"bases"`
+	Hidden bool           `json:"hidden"`
+}
+
+// GameSummary is the public summary of the current phase
+type GameSummary struct {
+	Phase       PhaseInfo       `json:"phase"`
+	Turn        string          `json:"turn"`
+	MoveCounter bool            `json:"MoveCounter"`
+	Players     []PlayerSummary `json:"players"`
+}
+
+func (a *GameApplication) Inspect(env rollmelette.EnvInspector, payload []byte) error {
+	query := InspectQuery{Query: GamesQuery}
+	if len(payload) != 0 {
+		err := json.Unmarshal(payload, &query)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal query: %w", err)
+		}
+	}
+
+	var args QueryArgs
+	if len(query.Args) != 0 {
+		err := json.Unmarshal(query.Args, &args)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal args: %w", err)
+		}
+	}
+
+	if handler, ok := appQueryHandlers[query.Query]; ok {
+		result, err := handler(a, args)
+		if err != nil {
+			return err
+		}
+		return report(env, result)
+	}
+
+	handler, ok := queryHandlers[query.Query]
+	if !ok {
+		return fmt.Errorf("invalid query: %v", query.Query)
+	}
+	game, ok := a.games[args.Game]
+	if !ok {
+		return fmt.Errorf("game not found: %v", args.Game)
+	}
+	result, err := handler(game, args)
+	if err != nil {
+		return err
+	}
+	return report(env, result)
+}
+
+// reportState reports the game state as seen by the player who sent the input, reports are public
+// so an anonymous game reports the view of no power at all rather than tie the sender to theirs
+func (a *Game) reportState(env rollmelette.EnvInspector, player common.Address) error {
+	if a.anonymous() {
+		return report(env, a.playerView(nil))
+	}
+	return report(env, a.playerView(a.powersOf(player)))
+}
+
+// viewer finds the powers a query sees the game as: the player's powers, or in an anonymous game
+// the power asked for, since answering for an address would tell which powers it plays
+func (a *Game) viewer(args QueryArgs) ([]string, error) {
+	if !a.anonymous() {
+		return a.powersOf(args.Player), nil
+	}
+	if args.Player != (common.Address{}) {
+		return nil, fmt.Errorf("anonymous games are viewed by power, not by player")
+	}
+	if args.Power == "" {
+		return nil, nil
+	}
+	if _, ok := a.state.Players[args.Power]; !ok {
+		return nil, fmt.Errorf("power not found: %v", args.Power)
+	}
+	return []string{args.Power}, nil
+}
+
+func report(env rollmelette.EnvInspector, value any) error {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	env.Report(bytes)
+	return nil
+}
+
+// playerView copies the game state as the given powers see it
+// Other powers' orders show up as the default hold order and their pending builds are omitted
+// until the phase is adjudicated, in a fog of war game the units out of sight are left out too
+// and in an anonymous game no address is shown
+// The hiding keeps each player's view to what they are meant to see, it keeps no secret: orders are
+// sent as inputs anyone can read on the base layer and queries aren't authenticated, so anyone
+// can ask for any player's view
+func (a *Game) playerView(powers []string) GameState {
+	view := a.state
+	view.Units = make(map[int]*Unit, len(a.state.Units))
+	for id, unit := range a.state.Units {
+		u := *unit
+		if !contains(powers, u.Power) {
+			u.CurrentOrder = Orders{
+				UnitID:    u.ID,
+				Ordertype: "hold",
+			}
+		}
+		view.Units[id] = &u
+	}
+
+	view.Players = make(map[string]*Team, len(a.state.Players))
+	for power, team := range a.state.Players {
+		t := *team
+		if !contains(powers, power) {
+			t.Builds = nil
+		}
+		view.Players[power] = &t
+	}
+	if a.FogOfWar {
+		a.fogView(&view, powers)
+	}
+	if a.anonymous() {
+		anonymousView(&view)
+	}
+	return view
+}
+
+func queryState(a *Game, args QueryArgs) (any, error) {
+	powers, err := a.viewer(args)
+	if err != nil {
+		return nil, err
+	}
+	return a.playerView(powers), nil
+}
+
+func querySummary(a *Game, args QueryArgs) (any, error) {
+	return GameSummary{
+		Phase:       a.state.Phase(),
+		Turn:        a.state.Turn,
+		MoveCounter: a.state.MoveCounter,
+		Players:     a.players(),
+	}, nil
+}
+
+func queryBoard(a *Game, args QueryArgs) (any, error) {
+	powers, err := a.viewer(args)
+	if err != nil {
+		return nil, err
+	}
+	return a.playerView(powers).Board, nil
+}
+
+func queryRegion(a *Game, args QueryArgs) (any, error) {
+	name, err := a.resolveRegion(args.Region)
+	if err != nil {
+		return nil, err
+	}
+	powers, err := a.viewer(args)
+	if err != nil {
+		return nil, err
+	}
+	return a.playerView(powers).Board[name], nil
+}
+
+// queryUnits lists the units of a power, or every unit when no power is given, hiding orders the
+// same way the state view does
+func queryUnits(a *Game, args QueryArgs) (any, error) {
+	powers, err := a.viewer(args)
+	if err != nil {
+		return nil, err
+	}
+	view := a.playerView(powers)
+	units := []*Unit{}
+	for _, unit := range view.Units {
+		if args.Power != "" && unit.Power != args.Power {
+			continue
+		}
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	return units, nil
+}
+
+// queryBuilds lists the pending builds of every power of the player, or of the power asked for in
+// an anonymous game, other players' builds stay out of it like in the state view
+func queryBuilds(a *Game, args QueryArgs) (any, error) {
+	powers, err := a.viewer(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(powers) == 0 {
+		return nil, fmt.Errorf("player not found: %v", args.Player)
+	}
+	view := a.playerView(powers)
+	builds := []*BuildArmyInput{}
+	for _, power := range powers {
+		builds = append(builds, view.Players[power].Builds...)
+	}
+	return builds, nil
+}
+
+func queryPhase(a *Game, args QueryArgs) (any, error) {
+	return a.state.Phase(), nil
+}
+
+func queryPlayers(a *Game, args QueryArgs) (any, error) {
+	return a.players(), nil
+}
+
+// players summarizes every power, anonymous games hide every player's address
+func (a *Game) players() []PlayerSummary {
+	players := []PlayerSummary{}
+	for _, team := range a.state.Players {
+		player := team.Player
+		hidden := a.anonymous() && !team.inCivilDisorder()
+		if hidden {
+			player = common.Address{}
+		}
+		players = append(players, PlayerSummary{
+			Name:   team.Name,
+			Player: player,
+			Ready:  team.Ready,
+			Units:  len(team.Armies),
+			Bases:  team.Bases,
+			Hidden: hidden,
+		})
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Name < players[j].Name
+	})
+	return players
+}
+
+// queryHistory returns the record of the given phase, or the labels of every adjudicated phase
+// when no phase is given
+func queryHistory(a *Game, args QueryArgs) (any, error) {
+	if args.Phase == "" {
+		return a.phaseLabels(), nil
+	}
+	record, err := a.phaseRecord(args.Phase)
+	if err != nil {
+		return nil, err
+	}
+	powers, err := a.viewer(args)
+	if err != nil {
+		return nil, err
+	}
+	return a.historyView(record, powers)
+}
+
+func queryLegalOrders(a *Game, args QueryArgs) (any, error) {
+	powers, err := a.viewer(args)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := a.playerView(powers).Units[args.Unit]; !ok {
+		return nil, fmt.Errorf("unit not found")
+	}
+	return a.LegalOrders(args.Unit, powers)
+}
+
+func queryGames(a *GameApplication, args QueryArgs) (any, error) {
+	games := []*Game{}
+	for _, game := range a.games {
+		games = append(games, game.publicView())
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].ID < games[j].ID
+	})
+	return games, nil
+}
+
+func queryGame(a *GameApplication, args QueryArgs) (any, error) {
+	game, ok := a.games[args.Game]
+	if !ok {
+		return nil, fmt.Errorf("game not found: %v", args.Game)
+	}
+	return game.publicView(), nil
+}
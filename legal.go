@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LegalOrders enumerates every order the unit can be given in the current board
+// Candidates are built from the unit's surroundings and kept only if validateOrder accepts them
+// and they break none of the treaties the unit's power is bound by, as the move handler does
+// In a fog of war game only the units the given powers can see are offered support
+func (a *Game) LegalOrders(unitID int, powers []string) ([]Orders, error) {
+	unit, ok := a.state.Units[unitID]
+	if !ok {
+		return nil, fmt.Errorf("unit not found")
+	}
+	position := a.state.Board[unit.Position]
+	owner := unit.Power
+
+	order := func(orderType string, from string, to string) Orders {
+		return Orders{
+			UnitID:     unit.ID,
+			Ordertype:  orderType,
+			OrderOwner: owner,
+			FromRegion: from,
+			ToRegion:   to,
+		}
+	}
+
+	// only the regions the unit's type can reach are worth ordering into
+	var neighbors []*Region
+	for _, name := range position.Neighbors {
+		if region, ok := a.state.Board[*name]; ok && isReachable(position, unit.SubPosition, unit.Type, *name) {
+			neighbors = append(neighbors, region)
+		}
+	}
+
+	var visible visibility
+	if a.FogOfWar {
+		visible = a.visibleRegions(powers)
+	}
+
+	candidates := []Orders{order("hold", unit.Position, "")}
+
+	for _, target := range neighbors {
+		candidates = append(candidates, a.moveCandidates(unit, order("move", unit.Position, target.Name))...)
+	}
+
+	for _, target := range neighbors {
+		if target.Occupied {
+			candidates = append(candidates, order("support hold", unit.Position, target.Name))
+		}
+		for _, other := range a.sortedUnits() {
+			if other.ID == unit.ID || other.Position == target.Name {
+				continue
+			}
+			if a.FogOfWar && !visible[other.Position] {
+				continue
+			}
+			if isReachable(a.state.Board[other.Position], other.SubPosition, other.Type, target.Name) {
+				candidates = append(candidates, order("support move", other.Position, target.Name))
+			}
+		}
+	}
+
+	if unit.Type == "navy" && position.Sea {
+		for _, from := range neighbors {
+			if !from.Occupied {
+				continue
+			}
+			for _, to := range neighbors {
+				if to.Name != from.Name {
+					candidates = append(candidates, order("convoy", from.Name, to.Name))
+				}
+			}
+		}
+	}
+
+	if unit.Type == "army" && position.Coastal {
+		for _, name := range a.sortedRegionNames() {
+			if name != unit.Position && a.state.Board[name].Coastal {
+				candidates = append(candidates, order("convoy move", unit.Position, name))
+			}
+		}
+	}
+
+	legal := []Orders{}
+	for _, candidate := range candidates {
+		valid, err := a.validateOrder(candidate)
+		if err == nil && a.checkTreaties(unit, valid) == nil {
+			legal = append(legal, valid)
+		}
+	}
+	return legal, nil
+}
+
+// moveCandidates expands a move into one candidate per coast when a navy moves into a region with
+// separate coasts
+func (a *Game) moveCandidates(unit *Unit, move Orders) []Orders {
+	if unit.Type != "navy" {
+		return []Orders{move}
+	}
+	move.FromSubRegion = unit.SubPosition
+	target := a.state.Board[move.ToRegion]
+	if len(target.Coasts) == 0 {
+		return []Orders{move}
+	}
+	var moves []Orders
+	for _, coast := range sortedKeys(target.Coasts) {
+		m := move
+		m.ToSubRegion = coast
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+func (a *Game) sortedUnits() []*Unit {
+	units := make([]*Unit, 0, len(a.state.Units))
+	for _, unit := range a.state.Units {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ID < units[j].ID
+	})
+	return units
+}
+
+func (a *Game) sortedRegionNames() []string {
+	return sortedKeys(a.state.Board)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
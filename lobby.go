@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rollmelette/rollmelette"
+)
+
+// How the powers left without a player are handled when a game has fewer players than powers
+const (
+	CivilDisorder = "disorder"
+	PowerDoubling = "double"
+)
+
+// Fewest players a game can be created for
+const MinPlayers = 2
+
+// CreateGamePayload is the payload for creating a new game
+// EntryFee is the amount each player must deposit to join, zero for a free game
+// Token is the ERC-20 token the fee is paid in, the fee is paid in Ether (wei) when it is empty
+// Scoring is the scoring system used once the game ends: dss (default), sos, carnage or cdiplo
+// Variant is the name of the variant the game is played with, the classic game by default
+// Players is the number of players, one per power of the variant by default, the powers left
+// over are Unassigned: in civil disorder (default), holding their units, or doubled up with the
+// players seated, handed out again from the first seat on
+// FogOfWar hides from each player what their units and supply centers don't border, the inputs
+// are public so it is no secret to anyone replaying them
+// Press is full (default), public or gunboat, gunboat games draw the powers at random and keep the
+// players anonymous
+// Treaties lets the powers sign treaties that the engine enforces on their orders
+type CreateGamePayload struct {
+	EntryFee   *big.Int       `json:"entryFee"`
+	Token      common.Address `json:"token"`
+	Scoring    string         `json:"scoring"`
+	RoundTime  int            `json:"roundTime"`
+	Variant    string         `json:"variant"`
+	Players    int            `json:"players"`
+	Unassigned string         `json:"unassigned"`
+	FogOfWar   bool           `json:"fogOfWar"`
+	Press      string         `json:"press"`
+	Treaties   bool           `json:"treaties"`
+}
+
+// VoteDrawPayload is the payload for voting for, or withdrawing the vote for, a draw
+type VoteDrawPayload struct {
+	Draw bool `json:"draw"`
+}
+
+// GameResult is how a game ended, either with a solo winner or a draw between the survivors
+type GameResult struct {
+	Winner string   `json:"winner"`
+	Draw   []string `json:"draw"`
+}
+
+func (a *GameApplication) handleCreateGame(
+	metadata rollmelette.Metadata,
+	inputPayload CreateGamePayload,
+) (*Game, error) {
+	entryFee := inputPayload.EntryFee
+	if entryFee == nil {
+		entryFee = big.NewInt(0)
+	}
+	if entryFee.Sign() < 0 {
+		return nil, fmt.Errorf("entry fee can't be negative")
+	}
+	scoring := inputPayload.Scoring
+	if scoring == "" {
+		scoring = DefaultScoring
+	}
+	_, err := scoringSystem(scoring)
+	if err != nil {
+		return nil, err
+	}
+	variant := inputPayload.Variant
+	if variant == "" {
+		variant = ClassicVariant
+	}
+	definition, err := variantDefinition(variant)
+	if err != nil {
+		return nil, err
+	}
+	players := inputPayload.Players
+	if players == 0 {
+		players = len(definition.powers())
+	}
+	if players < MinPlayers || players > len(definition.powers()) {
+		return nil, fmt.Errorf("variant %v is played by %v to %v players", variant, MinPlayers, len(definition.powers()))
+	}
+	press := inputPayload.Press
+	if press == "" {
+		press = DefaultPress
+	}
+	err = validPress(press)
+	if err != nil {
+		return nil, err
+	}
+	unassigned := inputPayload.Unassigned
+	if unassigned == "" {
+		unassigned = CivilDisorder
+	}
+	if unassigned != CivilDisorder && unassigned != PowerDoubling {
+		return nil, fmt.Errorf("invalid unassigned powers rule: %v", unassigned)
+	}
+
+	game := newGame(a.nextGameID, metadata.MsgSender, entryFee, inputPayload.RoundTime)
+	game.Token = inputPayload.Token
+	game.Scoring = scoring
+	game.Variant = variant
+	game.Players = players
+	game.Unassigned = unassigned
+	game.FogOfWar = inputPayload.FogOfWar
+	game.Press = press
+	game.Treaties = inputPayload.Treaties
+	a.games[game.ID] = game
+	a.nextGameID++
+	return game, nil
+}
+
+// handleJoinGame seats the player in the next free power, the game starts once every seat is taken
+// and anonymous games draw the powers then
+// Games with an entry fee must be joined through the Ether or ERC-20 portal with a deposit of the exact fee
+func (a *GameApplication) handleJoinGame(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	deposit rollmelette.Deposit,
+	gameID int,
+) (*Game, error) {
+	game, ok := a.games[gameID]
+	if !ok {
+		return nil, fmt.Errorf("game not found: %v", gameID)
+	}
+	if game.Status != GameOpen {
+		return nil, fmt.Errorf("game is not open to new players")
+	}
+
+	player := depositor(metadata, deposit)
+	for _, seat := range game.Seats {
+		if seat == player {
+			return nil, fmt.Errorf("player already joined this game")
+		}
+	}
+	err := a.collectEntryFee(env, game, player, deposit)
+	if err != nil {
+		return nil, err
+	}
+
+	game.Seats = append(game.Seats, player)
+	if len(game.Seats) == game.Players {
+		if game.anonymous() {
+			game.drawPowers(metadata)
+		}
+		game.start()
+	}
+	return game, nil
+}
+
+// handleCancelGame lets the creator call off a game that never started, refunding every player
+func (a *GameApplication) handleCancelGame(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	gameID int,
+) (*Game, error) {
+	game, ok := a.games[gameID]
+	if !ok {
+		return nil, fmt.Errorf("game not found: %v", gameID)
+	}
+	if game.Creator != metadata.MsgSender {
+		return nil, fmt.Errorf("only the creator can cancel the game")
+	}
+	if game.Status != GameOpen {
+		return nil, fmt.Errorf("can't cancel a game that already started")
+	}
+
+	payouts := make([]Payout, 0, len(game.Seats))
+	for _, seat := range game.Seats {
+		payouts = append(payouts, Payout{Player: seat, Amount: new(big.Int).Set(game.EntryFee)})
+	}
+	err := a.payOut(env, game, payouts)
+	if err != nil {
+		return nil, err
+	}
+	game.Status = GameCancelled
+	return game, nil
+}
+
+// handleLeaveGame gives a player their seat back in a game that didn't start, refunding the entry
+// fee, so nobody depends on the creator to get their funds out of a game that never fills up
+func (a *GameApplication) handleLeaveGame(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	gameID int,
+) (*Game, error) {
+	game, ok := a.games[gameID]
+	if !ok {
+		return nil, fmt.Errorf("game not found: %v", gameID)
+	}
+	if game.Status != GameOpen {
+		return nil, fmt.Errorf("can't leave a game that already started")
+	}
+	seat := -1
+	for i, player := range game.Seats {
+		if player == metadata.MsgSender {
+			seat = i
+		}
+	}
+	if seat < 0 {
+		return nil, fmt.Errorf("msg sender is not a player")
+	}
+
+	err := a.payOut(env, game, []Payout{{Player: metadata.MsgSender, Amount: new(big.Int).Set(game.EntryFee)}})
+	if err != nil {
+		return nil, err
+	}
+	game.Seats = append(game.Seats[:seat], game.Seats[seat+1:]...)
+	return game, nil
+}
+
+func (a *Game) handleVoteDraw(
+	metadata rollmelette.Metadata,
+	inputPayload VoteDrawPayload,
+) error {
+	teams := a.state.teamsOf(metadata.MsgSender)
+	if len(teams) == 0 {
+		return fmt.Errorf("msg sender is not a player")
+	}
+	surviving := false
+	for _, team := range teams {
+		surviving = surviving || a.surviving(team)
+	}
+	if !surviving {
+		return fmt.Errorf("eliminated players can't vote for a draw")
+	}
+
+	for _, team := range teams {
+		team.DrawVote = inputPayload.Draw
+	}
+	// powers in civil disorder have no say in the draw and aren't part of it
+	var survivors []string
+	for _, team := range a.state.Players {
+		if !a.surviving(team) || team.inCivilDisorder() {
+			continue
+		}
+		if !team.DrawVote {
+			return nil
+		}
+		survivors = append(survivors, team.Name)
+	}
+	sort.Strings(survivors)
+	a.Result = &GameResult{Draw: survivors}
+	return nil
+}
+
+// centers counts the supply centers of every power
+func (a *Game) centers() map[string]int {
+	centers := make(map[string]int, len(a.state.Players))
+	for _, team := range a.state.Players {
+		centers[team.Name] = a.state.centerCount(team.Name)
+	}
+	return centers
+}
+
+// supplyCenters counts the supply centers of the board
+func (g *GameState) supplyCenters() int {
+	count := 0
+	for _, region := range g.Board {
+		if region.SupplyCenter {
+			count++
+		}
+	}
+	return count
+}
+
+// checkVictory ends the game when a power holds the supply centers its variant asks for
+func (a *Game) checkVictory() {
+	for _, team := range a.state.Players {
+		if a.state.centerCount(team.Name) >= a.variant().VictoryCenters {
+			a.Result = &GameResult{Winner: team.Name}
+			return
+		}
+	}
+}
+
+// surviving tells whether the team still has units or supply centers
+func (a *Game) surviving(team *Team) bool {
+	for _, unit := range a.state.Units {
+		if unit.Power == team.Name {
+			return true
+		}
+	}
+	return a.state.centerCount(team.Name) > 0
+}
+
+func (g *GameState) centerCount(power string) int {
+	centers := 0
+	for _, region := range g.Board {
+		if region.SupplyCenter && region.Owner == power {
+			centers++
+		}
+	}
+	return centers
+}
+
+// teamsOf lists the teams the player controls, sorted by power
+func (g *GameState) teamsOf(player common.Address) []*Team {
+	var teams []*Team
+	for _, team := range g.Players {
+		if team.Player == player && !team.inCivilDisorder() {
+			teams = append(teams, team)
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].Name < teams[j].Name
+	})
+	return teams
+}
+
+// inCivilDisorder tells whether nobody plays the team's power
+func (t *Team) inCivilDisorder() bool {
+	return t.Player == common.Address{}
+}
+
+// seatOf returns the player seated in the power, or the zero address if the seat is empty
+func (a *Game) seatOf(power string) common.Address {
+	team, ok := a.state.Players[power]
+	if !ok {
+		return common.Address{}
+	}
+	return team.Player
+}
+
+// powerSeats returns the player of each power of the variant, in the variant's order
+// Powers left over once every seat has one are either left in civil disorder, with the zero
+// address, or handed out again from the first seat on
+func (a *Game) powerSeats() []common.Address {
+	powers := a.variant().powers()
+	seats := make([]common.Address, len(powers))
+	for i := range powers {
+		switch {
+		case i < len(a.Seats):
+			seats[i] = a.Seats[i]
+		case a.Unassigned == PowerDoubling:
+			seats[i] = a.Seats[i%len(a.Seats)]
+		}
+	}
+	return seats
+}
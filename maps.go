@@ -0,0 +1,368 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Map the classic variant is played on
+const DefaultMap = "standard"
+
+// Region types of a map definition
+const (
+	LandRegion  = "land"
+	CoastRegion = "coast"
+	SeaRegion   = "sea"
+)
+
+//go:embed maps/*.json
+var mapFiles embed.FS
+
+// maps holds every map shipped with the application by name, they are embedded in the binary
+// so a broken file is a bug caught as soon as the application starts
+var maps = mustLoadMaps()
+
+// MapDefinition is the declarative description of a board, new boards are added by dropping a
+// JSON file in the maps directory
+// Powers lists the powers in the order seats are handed to players
+// Units are the starting units, they get their IDs in the order they are listed
+type MapDefinition struct {
+	Name    string             `json:"name"`
+	Powers  []string           `json:"powers"`
+	Regions []RegionDefinition `json:"regions"`
+	Units   []UnitDefinition   `json:"units"`
+}
+
+// RegionDefinition describes a single region of the map
+// Abbreviation is the three letter name orders are written with in standard notation, e.g. PAR
+// Aliases are other names players may use for the region, e.g. other spellings or abbreviations
+// Type is land, coast or sea
+// Home is the power the supply center is a home center of and Owner the power controlling the
+// region when the game starts
+// Coasts lists, for regions with separate coasts, the locations fleets on each coast can move to,
+// those regions have no Fleet list of their own
+// Army and Fleet list the regions each kind of unit can move to from this region, fleets moving
+// into a region with separate coasts name the coast they reach, e.g. Spain/NC
+type RegionDefinition struct {
+	Name         string              `json:"name"`
+	Abbreviation string              `json:"abbreviation"`
+	Aliases      []string            `json:"aliases,omitempty"`
+	Type         string              `json:"type"`
+	SupplyCenter bool                `json:"supplyCenter,omitempty"`
+	Home         string              `json:"home,omitempty"`
+	Owner        string              `json:"owner,omitempty"`
+	Coasts       map[string][]string `json:"coasts,omitempty"`
+	Army         []string            `json:"army,omitempty"`
+	Fleet        []string            `json:"fleet,omitempty"`
+}
+
+// UnitDefinition is a unit present when the game starts, Type is army or navy
+type UnitDefinition struct {
+	Power  string `json:"power"`
+	Type   string `json:"type"`
+	Region string `json:"region"`
+	Coast  string `json:"coast,omitempty"`
+}
+
+func mustLoadMaps() map[string]*MapDefinition {
+	entries, err := mapFiles.ReadDir("maps")
+	if err != nil {
+		panic(err)
+	}
+	maps := make(map[string]*MapDefinition, len(entries))
+	for _, entry := range entries {
+		definition, err := parseMap(path.Join("maps", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		maps[definition.Name] = definition
+	}
+	return maps
+}
+
+func parseMap(name string) (*MapDefinition, error) {
+	bytes, err := mapFiles.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map %v: %w", name, err)
+	}
+	var definition MapDefinition
+	err = json.Unmarshal(bytes, &definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal map %v: %w", name, err)
+	}
+	return &definition, nil
+}
+
+func mapDefinition(name string) (*MapDefinition, error) {
+	definition, ok := maps[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid map: %v", name)
+	}
+	err := definition.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("map %v is not valid: %w", name, err)
+	}
+	return definition, nil
+}
+
+// Validate checks the map is coherent and returns every problem found
+// Every name referenced must be a region of the map, army and fleet adjacency must be symmetric
+// and every region reachable from any other, armies never border the sea, fleets only move
+// between coasts and seas, and starting units sit where their type can stand
+func (m *MapDefinition) Validate() error {
+	var problems []error
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	powers := make(map[string]bool, len(m.Powers))
+	for _, power := range m.Powers {
+		if powers[power] {
+			problem("power %v is listed twice", power)
+		}
+		powers[power] = true
+	}
+	if len(powers) == 0 {
+		problem("map has no powers")
+	}
+
+	regions := make(map[string]*RegionDefinition, len(m.Regions))
+	names := make(map[string]string, len(m.Regions))
+	for i := range m.Regions {
+		region := &m.Regions[i]
+		if _, ok := regions[region.Name]; ok {
+			problem("region %v is listed twice", region.Name)
+		}
+		regions[region.Name] = region
+		if len(region.Abbreviation) != 3 || normalizeName(region.Abbreviation) != strings.ToLower(region.Abbreviation) {
+			problem("region %v has invalid abbreviation %q", region.Name, region.Abbreviation)
+		}
+		// players refer to regions by any of their names, none can be shared with another region
+		for _, name := range append([]string{region.Name, region.Abbreviation}, region.Aliases...) {
+			if normalizeName(name) == "" {
+				continue
+			}
+			if other, ok := names[normalizeName(name)]; ok && other != region.Name {
+				problem("regions %v and %v both go by %v", other, region.Name, name)
+			}
+			names[normalizeName(name)] = region.Name
+		}
+	}
+
+	adjacent := func(list []string, name string) bool {
+		for _, item := range list {
+			if item == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, region := range m.Regions {
+		switch region.Type {
+		case LandRegion, CoastRegion, SeaRegion:
+		default:
+			problem("region %v has invalid type %v", region.Name, region.Type)
+		}
+		if region.Type == SeaRegion && (region.SupplyCenter || region.Owner != "" || len(region.Army) != 0) {
+			problem("sea region %v can't be a supply center, be owned or be reached by armies", region.Name)
+		}
+		if region.Type == LandRegion && len(region.Fleet) != 0 {
+			problem("land region %v can't be reached by fleets", region.Name)
+		}
+		if len(region.Coasts) != 0 && region.Type != CoastRegion {
+			problem("region %v has coasts but is not a coastal region", region.Name)
+		}
+		if region.Home != "" && !region.SupplyCenter {
+			problem("home region %v is not a supply center", region.Name)
+		}
+		for _, power := range []string{region.Home, region.Owner} {
+			if power != "" && !powers[power] {
+				problem("region %v refers to unknown power %v", region.Name, power)
+			}
+		}
+
+		for _, name := range region.Army {
+			neighbor, ok := regions[name]
+			if !ok {
+				problem("region %v borders unknown region %v", region.Name, name)
+				continue
+			}
+			if neighbor.Type == SeaRegion {
+				problem("armies can't move from %v to sea region %v", region.Name, name)
+			}
+			if !adjacent(neighbor.Army, region.Name) {
+				problem("armies can move from %v to %v but not back", region.Name, name)
+			}
+		}
+		if len(region.Coasts) != 0 && len(region.Fleet) != 0 {
+			problem("region %v has coasts, fleets move from each coast instead of the region", region.Name)
+		}
+	}
+
+	fleetLocations := m.fleetLocations()
+	for _, from := range sortedKeys(fleetLocations) {
+		for _, to := range fleetLocations[from] {
+			name, coast := splitLocation(to)
+			neighbor, ok := regions[name]
+			if !ok {
+				problem("region %v borders unknown region %v", from, to)
+				continue
+			}
+			if neighbor.Type == LandRegion {
+				problem("fleets can't move from %v to land region %v", from, to)
+				continue
+			}
+			if _, ok := neighbor.Coasts[coast]; coast != "" && !ok {
+				problem("%v borders unknown coast %v", from, to)
+				continue
+			}
+			if coast == "" && len(neighbor.Coasts) != 0 {
+				problem("fleets moving from %v to %v must name the coast they reach", from, to)
+				continue
+			}
+			if !adjacent(fleetLocations[to], from) {
+				problem("fleets can move from %v to %v but not back", from, to)
+			}
+		}
+	}
+
+	if len(m.Regions) != 0 {
+		reached := map[string]bool{m.Regions[0].Name: true}
+		queue := []string{m.Regions[0].Name}
+		for len(queue) != 0 {
+			region := regions[queue[0]]
+			queue = queue[1:]
+			for _, name := range region.neighbors() {
+				if _, ok := regions[name]; ok && !reached[name] {
+					reached[name] = true
+					queue = append(queue, name)
+				}
+			}
+		}
+		for _, region := range m.Regions {
+			if !reached[region.Name] {
+				problem("region %v can't be reached from %v", region.Name, m.Regions[0].Name)
+			}
+		}
+	}
+
+	occupied := make(map[string]bool, len(m.Units))
+	for _, unit := range m.Units {
+		if !powers[unit.Power] {
+			problem("unit in %v belongs to unknown power %v", unit.Region, unit.Power)
+		}
+		region, ok := regions[unit.Region]
+		if !ok {
+			problem("unit of %v starts in unknown region %v", unit.Power, unit.Region)
+			continue
+		}
+		if occupied[unit.Region] {
+			problem("more than one unit starts in %v", unit.Region)
+		}
+		occupied[unit.Region] = true
+		switch unit.Type {
+		case "army":
+			if region.Type == SeaRegion {
+				problem("army of %v can't start in sea region %v", unit.Power, unit.Region)
+			}
+			if unit.Coast != "" {
+				problem("army of %v in %v can't start on a coast", unit.Power, unit.Region)
+			}
+		case "navy":
+			if region.Type == LandRegion {
+				problem("navy of %v can't start in land region %v", unit.Power, unit.Region)
+			}
+			if _, ok := region.Coasts[unit.Coast]; len(region.Coasts) != 0 && !ok {
+				problem("navy of %v in %v must start on one of its coasts", unit.Power, unit.Region)
+			}
+			if len(region.Coasts) == 0 && unit.Coast != "" {
+				problem("navy of %v in %v starts on a coast the region doesn't have", unit.Power, unit.Region)
+			}
+		default:
+			problem("unit of %v in %v has invalid type %v", unit.Power, unit.Region, unit.Type)
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// region looks up a region of the map by name, nil when the map has no such region
+func (m *MapDefinition) region(name string) *RegionDefinition {
+	for i := range m.Regions {
+		if m.Regions[i].Name == name {
+			return &m.Regions[i]
+		}
+	}
+	return nil
+}
+
+// fleetLocations maps every location a fleet can stand on to the locations it can move to,
+// regions with separate coasts are a location per coast
+func (m *MapDefinition) fleetLocations() map[string][]string {
+	locations := make(map[string][]string)
+	for _, region := range m.Regions {
+		if region.Type == LandRegion {
+			continue
+		}
+		if len(region.Coasts) == 0 {
+			locations[region.Name] = region.Fleet
+		}
+		for coast, neighbors := range region.Coasts {
+			locations[location(region.Name, coast)] = neighbors
+		}
+	}
+	return locations
+}
+
+// neighbors lists every region either kind of unit can move to, without coasts
+func (r *RegionDefinition) neighbors() []string {
+	var neighbors []string
+	seen := make(map[string]bool)
+	add := func(locations []string) {
+		for _, name := range locations {
+			name, _ = splitLocation(name)
+			if !seen[name] {
+				seen[name] = true
+				neighbors = append(neighbors, name)
+			}
+		}
+	}
+	add(r.Army)
+	add(r.Fleet)
+	for _, coast := range sortedKeys(r.Coasts) {
+		add(r.Coasts[coast])
+	}
+	return neighbors
+}
+
+// location is the name of a region's coast as used in adjacency lists, e.g. Spain/NC, or the
+// region's name when no coast is given
+func location(region string, coast string) string {
+	if coast == "" {
+		return region
+	}
+	return region + "/" + coast
+}
+
+// normalizeName is the form region names are compared in, lower case letters and digits only so
+// St. Petersburg, st petersburg and StPetersburg are the same name
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// splitLocation splits a location into its region and coast
+func splitLocation(name string) (string, string) {
+	region, coast, _ := strings.Cut(name, "/")
+	return region, coast
+}
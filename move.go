@@ -3,13 +3,63 @@ package main
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/rollmelette/rollmelette"
 )
 
-func (a *GameApplication) handleMoveArmy(
+func (a *Game) handleMoveArmy(
 	metadata rollmelette.Metadata,
 	inputPayload GiveOrderPayload,
 ) error {
+	unit, orders, err := a.moveOrder(metadata.MsgSender, inputPayload)
+	if err != nil {
+		return err
+	}
+	unit.CurrentOrder = orders
+	a.state.Players[unit.Power].Submitted = true
+
+	return nil
+}
+
+// moveOrder checks the player can give the order to the unit and returns the order to store
+// without storing it
+func (a *Game) moveOrder(player common.Address, inputPayload GiveOrderPayload) (*Unit, Orders, error) {
+	if a.state.Turn != "move" {
+		return nil, Orders{}, fmt.Errorf("can't move an army outside of movement phase")
+	}
+	unit, ok := a.state.Units[inputPayload.UnitID]
+	if !ok {
+		return nil, Orders{}, fmt.Errorf("unit not found")
+	}
+	if a.state.Players[unit.Power].Player != player {
+		return nil, Orders{}, fmt.Errorf("can't move another player's army")
+	}
+	var err error
+	inputPayload.FromRegion, inputPayload.FromSubRegion, err = a.resolveDestination(inputPayload.FromRegion, inputPayload.FromSubRegion)
+	if err != nil {
+		return nil, Orders{}, err
+	}
+	if inputPayload.Ordertype != "hold" || inputPayload.ToRegion != "" {
+		inputPayload.ToRegion, inputPayload.ToSubRegion, err = a.resolveDestination(inputPayload.ToRegion, inputPayload.ToSubRegion)
+		if err != nil {
+			return nil, Orders{}, err
+		}
+	}
+
+	orders, err := a.validateOrder(inputPayload)
+	if err != nil {
+		return nil, Orders{}, err
+	}
+	err = a.checkTreaties(unit, orders)
+	if err != nil {
+		return nil, Orders{}, err
+	}
+	return unit, orders, nil
+}
+
+// validateOrder checks the order against the board and returns it as it should be stored
+// It is shared by the move handler and the legal order generator so both always agree
+func (a *Game) validateOrder(inputPayload Orders) (Orders, error) {
 
 	moveSet := map[string]bool{
 		"move":         true,
@@ -20,115 +70,98 @@ func (a *GameApplication) handleMoveArmy(
 		"convoy move":  true,
 	}
 
-	if a.state.Turn != "move" {
-		return fmt.Errorf("can't move an army outside of movement phase")
-	}
-	if _, ok := a.state.Players[metadata.MsgSender].Armies[inputPayload.UnitID]; !ok {
-		return fmt.Errorf("can't move another player's army")
-	}
 	if !a.state.Board[inputPayload.FromRegion].Occupied {
-		return fmt.Errorf("cant order an army to move from an empty region")
-	}
-	if metadata.MsgSender != a.state.Units[inputPayload.UnitID].Owner {
-		return fmt.Errorf("cant order an army that dont belong to you")
+		return Orders{}, fmt.Errorf("cant order an army to move from an empty region")
 	}
 	if !moveSet[inputPayload.Ordertype] {
-		return fmt.Errorf("invalid order")
+		return Orders{}, fmt.Errorf("invalid order")
 	}
 
 	if inputPayload.Ordertype == "move" {
 		if a.state.Units[inputPayload.UnitID].Position != inputPayload.FromRegion {
-			return fmt.Errorf("your army is not there")
+			return Orders{}, fmt.Errorf("your army is not there")
 		}
 		if a.state.Units[inputPayload.UnitID].Type == "army" && a.state.Board[inputPayload.ToRegion].Sea {
-			return fmt.Errorf("cant send an army into the sea")
+			return Orders{}, fmt.Errorf("cant send an army into the sea")
 		}
 
 		if a.state.Units[inputPayload.UnitID].Type == "navy" && !a.state.Board[inputPayload.ToRegion].Sea && !a.state.Board[inputPayload.ToRegion].Coastal {
-			return fmt.Errorf("cant send a ship inland")
+			return Orders{}, fmt.Errorf("cant send a ship inland")
 		}
-		if !isConnected(a.state.Board[inputPayload.FromRegion], &inputPayload.ToRegion) {
-			return fmt.Errorf("cant move to non adjacent territory")
+		if a.state.Units[inputPayload.UnitID].Type != "navy" && !isReachable(a.state.Board[inputPayload.FromRegion], "", a.state.Units[inputPayload.UnitID].Type, inputPayload.ToRegion) {
+			return Orders{}, fmt.Errorf("cant move to non adjacent territory")
 		}
-		MoveHarbor := false
-		for _, region := range SubRegionsList {
-			if (region == inputPayload.ToRegion || region == inputPayload.FromRegion) && a.state.Units[inputPayload.UnitID].Type == "navy" {
-				MoveHarbor = true
-			}
-		}
-		//navy stationed in/moving into one of the sub regions
-		if MoveHarbor {
-			if (inputPayload.FromSubRegion != "" && inputPayload.ToSubRegion != "") != (inputPayload.FromSubRegion == "" && inputPayload.ToSubRegion == "") {
-				return fmt.Errorf("need to specify the sub region and can't move directly between sub regions")
-			}
-			if inputPayload.FromSubRegion != "" {
-				if !isSubRegionConnected(a.state.Board[inputPayload.FromRegion].SubRegions[inputPayload.FromSubRegion], inputPayload.ToRegion) {
-					return fmt.Errorf("cant reach this region from this harbor")
-				}
-			} else {
-				if !isSubRegionConnected(a.state.Board[inputPayload.ToRegion].SubRegions[inputPayload.ToSubRegion], inputPayload.FromRegion) {
-					return fmt.Errorf("cant move to non adjacent harbor")
-				}
-			}
-		} else {
-			inputPayload.FromSubRegion = ""
-			inputPayload.ToSubRegion = ""
+		//navies move between coasts, the order keeps the coast they leave and the one they reach
+		toCoast, err := a.checkCoasts(a.state.Units[inputPayload.UnitID], inputPayload.FromSubRegion, inputPayload.ToRegion, inputPayload.ToSubRegion)
+		if err != nil {
+			return Orders{}, err
 		}
-
+		inputPayload.FromSubRegion = a.state.Units[inputPayload.UnitID].SubPosition
+		inputPayload.ToSubRegion = toCoast
+	} else {
+		inputPayload.FromSubRegion = ""
+		inputPayload.ToSubRegion = ""
 	}
 
+	// a unit can only support into a region it could move to itself
+	supporter := a.state.Units[inputPayload.UnitID]
 	if inputPayload.Ordertype == "support move" {
-		if !isConnected(a.state.Board[inputPayload.FromRegion], &inputPayload.ToRegion) ||
-			!isConnected(a.state.Board[inputPayload.ToRegion], &a.state.Units[inputPayload.UnitID].Position) {
-			return fmt.Errorf("cant support move to nor from non adjacent territories")
+		supported := supporter
+		for _, unit := range a.state.Units {
+			if unit.Position == inputPayload.FromRegion {
+				supported = unit
+			}
+		}
+		if !isReachable(a.state.Board[inputPayload.FromRegion], supported.SubPosition, supported.Type, inputPayload.ToRegion) ||
+			!isReachable(a.state.Board[supporter.Position], supporter.SubPosition, supporter.Type, inputPayload.ToRegion) {
+			return Orders{}, fmt.Errorf("cant support move to nor from non adjacent territories")
 		}
 	}
 
 	if inputPayload.Ordertype == "support hold" {
-		if !isConnected(a.state.Board[a.state.Units[inputPayload.UnitID].Position], &inputPayload.ToRegion) {
-			return fmt.Errorf("cant support hold to non adjacent territory")
+		if !isReachable(a.state.Board[supporter.Position], supporter.SubPosition, supporter.Type, inputPayload.ToRegion) {
+			return Orders{}, fmt.Errorf("cant support hold to non adjacent territory")
 		}
 	}
 
 	if inputPayload.Ordertype == "convoy" {
 		if a.state.Units[inputPayload.UnitID].Type != "navy" || !a.state.Board[a.state.Units[inputPayload.UnitID].Position].Sea {
-			return fmt.Errorf("cant convoy if the unit is not at sea")
+			return Orders{}, fmt.Errorf("cant convoy if the unit is not at sea")
+		}
+		if !a.state.Board[inputPayload.FromRegion].Coastal || !a.state.Board[inputPayload.ToRegion].Coastal {
+			return Orders{}, fmt.Errorf("cant convoy from nor to landlocked regions")
 		}
-		if !isConnected(a.state.Board[inputPayload.FromRegion], &a.state.Units[inputPayload.UnitID].Position) ||
-			!isConnected(a.state.Board[inputPayload.ToRegion], &a.state.Units[inputPayload.UnitID].Position) {
-			return fmt.Errorf("cant convoy from or to Regions that your sea tile does not touch")
+		sea := a.state.Board[a.state.Units[inputPayload.UnitID].Position]
+		if !isReachable(sea, "", "navy", inputPayload.FromRegion) || !isReachable(sea, "", "navy", inputPayload.ToRegion) {
+			return Orders{}, fmt.Errorf("cant convoy from or to Regions that your sea tile does not touch")
 		}
 	}
 
 	if inputPayload.Ordertype == "convoy move" {
 		if a.state.Units[inputPayload.UnitID].Type != "army" {
-			return fmt.Errorf("cant convoy another boat")
+			return Orders{}, fmt.Errorf("cant convoy another boat")
 		}
 		if !a.state.Board[inputPayload.FromRegion].Coastal || !a.state.Board[inputPayload.ToRegion].Coastal {
-			return fmt.Errorf("cant convoy from nor to landlocked regions")
+			return Orders{}, fmt.Errorf("cant convoy from nor to landlocked regions")
 		}
 		var seaConnected []string
-		for _, region := range a.state.Board[inputPayload.FromRegion].Neighbors {
-			if a.state.Board[*region].Sea && a.state.Board[*region].Occupied {
-				seaConnected = append(seaConnected, *region)
+		for _, region := range a.state.Board[inputPayload.FromRegion].coastlines() {
+			region, _ = splitLocation(region)
+			if a.state.Board[region].Sea && a.state.Board[region].Occupied {
+				seaConnected = append(seaConnected, region)
 			}
 		}
 		if len(seaConnected) < 1 {
-			return fmt.Errorf("no available boats to convoy")
+			return Orders{}, fmt.Errorf("no available boats to convoy")
 		}
 		connectedBySea := false
-		fmt.Println("mar con")
 		for _, sea := range seaConnected {
-			fmt.Println("mar con", sea)
-			for _, coast := range a.state.Board[sea].Neighbors {
-				fmt.Println("com quem", *coast, string(a.state.Board[inputPayload.ToRegion].Name))
-				if *coast == a.state.Board[inputPayload.ToRegion].Name {
-					connectedBySea = true
-				}
+			if isReachable(a.state.Board[sea], "", "navy", inputPayload.ToRegion) {
+				connectedBySea = true
 			}
 		}
 		if !connectedBySea {
-			return fmt.Errorf("cant convoy to a coast more than one sea tile away")
+			return Orders{}, fmt.Errorf("cant convoy to a coast more than one sea tile away")
 		}
 	}
 	orders := Orders{
@@ -140,48 +173,27 @@ func (a *GameApplication) handleMoveArmy(
 		FromRegion:    inputPayload.FromRegion,
 		FromSubRegion: inputPayload.FromSubRegion,
 	}
-	a.state.Units[inputPayload.UnitID].CurrentOrder = orders
-
-	return nil
+	return orders, nil
 }
 
-func (a *GameApplication) prepareMoves() []MoveOrder {
+func (a *Game) prepareMoves() []MoveOrder {
 	var moveOrders []MoveOrder
 	for _, unit := range a.state.Units {
 		if unit.CurrentOrder.Ordertype == "move" || unit.CurrentOrder.Ordertype == "convoy move" {
 			if unit.CurrentOrder.Ordertype == "convoy move" {
-				goodConvoy := false
-				convoyPosition := ""
-				for _, convoyUnit := range a.state.Units {
-					if convoyUnit.CurrentOrder.FromRegion == unit.CurrentOrder.FromRegion && convoyUnit.CurrentOrder.Ordertype == "convoy" && convoyUnit.CurrentOrder.ToRegion == unit.CurrentOrder.ToRegion {
-						//convoy is executed
-						goodConvoy = true
-						convoyPosition = convoyUnit.Position
-					}
-
-				}
-				if goodConvoy {
-					convoyAttacked := false
-					for _, otherUnit := range a.state.Units {
-						if otherUnit.CurrentOrder.Ordertype == "move" && otherUnit.CurrentOrder.ToRegion == convoyPosition {
-							//convoy is attacked
-							convoyAttacked = true
-						}
-					}
-					if !convoyAttacked {
-						moveOrders = append(moveOrders, MoveOrder{
-							Unit:       unit,
-							FromRegion: unit.Position,
-							ToRegion:   unit.CurrentOrder.ToRegion,
-						})
-					}
+				if a.convoyFailure(unit) == "" {
+					moveOrders = append(moveOrders, MoveOrder{
+						Unit:       unit,
+						FromRegion: unit.Position,
+						ToRegion:   unit.CurrentOrder.ToRegion,
+					})
 				}
 			} else {
 				moveOrders = append(moveOrders, MoveOrder{
-					Unit:       unit,
-					FromRegion: unit.Position,
-					ToRegion:   unit.CurrentOrder.ToRegion,
-					SubRegion:  unit.CurrentOrder.ToSubRegion + unit.CurrentOrder.FromSubRegion,
+					Unit:        unit,
+					FromRegion:  unit.Position,
+					ToRegion:    unit.CurrentOrder.ToRegion,
+					ToSubRegion: unit.CurrentOrder.ToSubRegion,
 				})
 			}
 		}
@@ -189,6 +201,27 @@ func (a *GameApplication) prepareMoves() []MoveOrder {
 	return moveOrders
 }
 
+// convoyFailure returns why a convoy move can't go through, or an empty string if it can
+func (a *Game) convoyFailure(unit *Unit) string {
+	convoyPosition := ""
+	for _, convoyUnit := range a.state.Units {
+		if convoyUnit.CurrentOrder.FromRegion == unit.CurrentOrder.FromRegion && convoyUnit.CurrentOrder.Ordertype == "convoy" && convoyUnit.CurrentOrder.ToRegion == unit.CurrentOrder.ToRegion {
+			//convoy is executed
+			convoyPosition = convoyUnit.Position
+		}
+	}
+	if convoyPosition == "" {
+		return "no matching convoy"
+	}
+	for _, otherUnit := range a.state.Units {
+		if otherUnit.CurrentOrder.Ordertype == "move" && otherUnit.CurrentOrder.ToRegion == convoyPosition {
+			//convoy is attacked
+			return "convoy disrupted"
+		}
+	}
+	return ""
+}
+
 func calculateSupportCount(unit *Unit, gameState *GameState) int {
 	supportCount := 0
 	for _, supportingUnit := range gameState.Units {
@@ -204,31 +237,26 @@ func calculateSupportCount(unit *Unit, gameState *GameState) int {
 	return supportCount
 }
 
-func (a *GameApplication) executeMoves(moveOrders []MoveOrder) {
+func (a *Game) executeMoves(moveOrders []MoveOrder) {
 	destinationMap := make(map[string][]*Unit)
 	for _, moveOrder := range moveOrders {
 		destinationMap[moveOrder.ToRegion] = append(destinationMap[moveOrder.ToRegion], moveOrder.Unit)
 	}
 
 	for _, moveOrder := range moveOrders {
-		fmt.Println("move orders", moveOrder.ToRegion)
 		unitsMovingToDestination := destinationMap[moveOrder.ToRegion]
 		if len(unitsMovingToDestination) == 1 && !a.state.Board[moveOrder.ToRegion].Occupied {
 			// No conflict, move the unit
-			fmt.Println("move to", moveOrder.ToRegion)
-			fmt.Println("move from", moveOrder.ToRegion)
 			moveOrder.Unit.Position = moveOrder.ToRegion
 			a.state.Board[moveOrder.ToRegion].Occupied = true
 			a.state.Board[moveOrder.FromRegion].Occupied = false
 			a.state.Units[moveOrder.Unit.ID].Position = moveOrder.ToRegion
-			a.state.Units[moveOrder.Unit.ID].SubPosition = moveOrder.SubRegion
+			a.state.Units[moveOrder.Unit.ID].SubPosition = moveOrder.ToSubRegion
 
 			a.state.Units[moveOrder.Unit.ID].CurrentOrder.Ordertype = "hold"
 			a.state.Units[moveOrder.Unit.ID].CurrentOrder.FromRegion = ""
 			a.state.Units[moveOrder.Unit.ID].CurrentOrder.OrderOwner = ""
 			a.state.Units[moveOrder.Unit.ID].CurrentOrder.ToRegion = ""
-
-			fmt.Println("occupied", a.state.Board[moveOrder.FromRegion].Occupied, moveOrder.FromRegion)
 		}
 	}
 }
@@ -281,9 +309,7 @@ func ResolveMovementConflicts(gameState *GameState) {
 }
 
 func ResolveMoveToOccupied(movingUnits []*Unit, occupyingUnit *Unit, gameState *GameState) ConflictOutcome {
-	fmt.Println("Occupied: ", len(movingUnits), occupyingUnit.Position)
 	occupyingSupportCount := calculateSupportCount(occupyingUnit, gameState)
-	fmt.Println("sup hold: ", occupyingSupportCount)
 	var maxSupport int
 	var winningUnit *Unit
 	tie := false
@@ -376,19 +402,20 @@ func UpdateGameState(outcome ConflictOutcome, a *GameState) {
 		a.Units[outcome.Winner.ID].Position = outcome.Winner.CurrentOrder.ToRegion
 		a.Units[outcome.Winner.ID].SubPosition = outcome.Winner.CurrentOrder.ToSubRegion
 		a.Board[outcome.Winner.Position].Occupied = true
-		a.Board[outcome.Winner.Position].Owner = a.Players[outcome.Winner.Owner].Name
+		a.Board[outcome.Winner.Position].Owner = outcome.Winner.Power
 
 		if a.Units[outcome.Loser.ID].Position == a.Units[outcome.Winner.ID].CurrentOrder.ToRegion {
 			a.Units[outcome.Loser.ID].Retreating = a.Units[outcome.Winner.ID].CurrentOrder.FromRegion
 		}
 	} else {
 		a.Units[outcome.Winner.ID].Position = outcome.Winner.CurrentOrder.ToRegion
+		a.Units[outcome.Winner.ID].SubPosition = outcome.Winner.CurrentOrder.ToSubRegion
 		a.Board[outcome.Winner.Position].Occupied = true
-		a.Board[outcome.Winner.Position].Owner = a.Players[outcome.Winner.Owner].Name
+		a.Board[outcome.Winner.Position].Owner = outcome.Winner.Power
 	}
 }
 
-func ResetOrders(a *GameApplication) {
+func ResetOrders(a *Game) {
 	for _, unit := range a.state.Units {
 		unit.CurrentOrder.Ordertype = "hold"
 		unit.CurrentOrder.FromRegion = ""
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Unit letters of standard notation
+var notationTypes = map[string]string{
+	"A": "army",
+	"F": "navy",
+}
+
+// orderPayload reads the payload of a move order, either a GiveOrderPayload or a string holding
+// the order in standard notation
+func (a *Game) orderPayload(payload json.RawMessage) (GiveOrderPayload, error) {
+	var notation string
+	if json.Unmarshal(payload, &notation) == nil {
+		return a.parseOrder(notation)
+	}
+	var order GiveOrderPayload
+	err := json.Unmarshal(payload, &order)
+	if err != nil {
+		return Orders{}, fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+	return order, nil
+}
+
+// parseOrder turns an order written in standard notation into the order of the unit it names
+// Regions are written with their abbreviation, or any other name of a single word, and coasts
+// after a slash or in parentheses:
+//
+//	A PAR H, A PAR - BUR, A LON - NWY VIA, F NTH C A LON - NWY,
+//	A MUN S A BER, A MUN S A BER - SIL, F STP/SC - BOT, F MAO - SPA(NC)
+//
+// Armies moving to a region they don't border are convoyed
+func (a *Game) parseOrder(notation string) (Orders, error) {
+	tokens := strings.Fields(strings.ToUpper(notation))
+	invalid := fmt.Errorf("invalid order notation: %v", notation)
+	if len(tokens) < 2 {
+		return Orders{}, invalid
+	}
+	unit, coast, err := a.notationUnit(tokens[0], tokens[1])
+	if err != nil {
+		return Orders{}, err
+	}
+	order := Orders{
+		UnitID:        unit.ID,
+		OrderOwner:    unit.Power,
+		FromRegion:    unit.Position,
+		FromSubRegion: coast,
+	}
+	tokens = tokens[2:]
+
+	switch {
+	case len(tokens) == 0 || (len(tokens) == 1 && (tokens[0] == "H" || tokens[0] == "HOLD")):
+		order.Ordertype = "hold"
+	case tokens[0] == "-" && len(tokens) >= 2:
+		order.Ordertype = "move"
+		order.ToRegion, order.ToSubRegion, err = a.resolveLocation(tokens[1])
+		if err != nil {
+			return Orders{}, err
+		}
+		via := tokens[2:]
+		switch {
+		case len(via) == 0:
+			if unit.Type == "army" && !isReachable(a.state.Board[unit.Position], "", unit.Type, order.ToRegion) {
+				order.Ordertype = "convoy move"
+			}
+		case via[0] == "VIA" && (len(via) == 1 || (len(via) == 2 && via[1] == "CONVOY")):
+			order.Ordertype = "convoy move"
+		default:
+			return Orders{}, invalid
+		}
+	case tokens[0] == "S" && len(tokens) >= 3:
+		supported, _, err := a.notationUnit(tokens[1], tokens[2])
+		if err != nil {
+			return Orders{}, err
+		}
+		switch {
+		case len(tokens) == 3 || (len(tokens) == 4 && tokens[3] == "H"):
+			order.Ordertype = "support hold"
+			order.ToRegion = supported.Position
+		case len(tokens) == 5 && tokens[3] == "-":
+			order.Ordertype = "support move"
+			order.FromRegion = supported.Position
+			order.ToRegion, _, err = a.resolveLocation(tokens[4])
+			if err != nil {
+				return Orders{}, err
+			}
+		default:
+			return Orders{}, invalid
+		}
+	case tokens[0] == "C" && len(tokens) == 5 && tokens[3] == "-":
+		convoyed, _, err := a.notationUnit(tokens[1], tokens[2])
+		if err != nil {
+			return Orders{}, err
+		}
+		order.Ordertype = "convoy"
+		order.FromRegion = convoyed.Position
+		order.ToRegion, _, err = a.resolveLocation(tokens[4])
+		if err != nil {
+			return Orders{}, err
+		}
+	default:
+		return Orders{}, invalid
+	}
+	if order.Ordertype != "move" {
+		order.FromSubRegion = ""
+	}
+	return order, nil
+}
+
+// notationUnit finds the unit of the given letter in the location, along with the coast named
+func (a *Game) notationUnit(letter string, location string) (*Unit, string, error) {
+	unitType, ok := notationTypes[letter]
+	if !ok {
+		return nil, "", fmt.Errorf("invalid unit type: %v", letter)
+	}
+	region, coast, err := a.resolveLocation(location)
+	if err != nil {
+		return nil, "", err
+	}
+	unit := a.state.getUnitAtPosition(region)
+	if unit == nil || unit.Type != unitType {
+		return nil, "", fmt.Errorf("no %v in %v", unitType, region)
+	}
+	return unit, coast, nil
+}
+
+// formatOrder writes the unit's current order in standard notation, the type of a supported or
+// convoyed unit is looked up among the units given
+func (a *Game) formatOrder(unit Unit, units map[int]Unit) string {
+	order := unit.CurrentOrder
+	abbreviation := func(region string, coast string) string {
+		name := region
+		if board, ok := a.state.Board[region]; ok {
+			name = board.Abbreviation
+		}
+		return location(name, coast)
+	}
+	letter := func(unitType string) string {
+		if unitType == "navy" {
+			return "F"
+		}
+		return "A"
+	}
+	other := func(region string) string {
+		for _, other := range units {
+			if other.Position == region {
+				return letter(other.Type) + " " + abbreviation(region, "")
+			}
+		}
+		return "A " + abbreviation(region, "")
+	}
+
+	text := letter(unit.Type) + " " + abbreviation(unit.Position, unit.SubPosition)
+	switch order.Ordertype {
+	case "move":
+		return text + " - " + abbreviation(order.ToRegion, order.ToSubRegion)
+	case "convoy move":
+		return text + " - " + abbreviation(order.ToRegion, "") + " VIA"
+	case "support hold":
+		return text + " S " + other(order.ToRegion)
+	case "support move":
+		return text + " S " + other(order.FromRegion) + " - " + abbreviation(order.ToRegion, "")
+	case "convoy":
+		return text + " C " + other(order.FromRegion) + " - " + abbreviation(order.ToRegion, "")
+	case "delete":
+		return text + " D"
+	default:
+		return text + " H"
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rollmelette/rollmelette"
+)
+
+// SubmitOrdersPayload is the payload for submitting every order of a power for the phase at once
+// Power can be left out by players controlling a single power
+// Orders, Retreats and Builds are the move, retreat and build orders, only the ones of the
+// current phase can be given
+// Notation holds more move orders written in standard notation, e.g. "A PAR - BUR"
+// Ready marks the player ready once the orders are stored
+type SubmitOrdersPayload struct {
+	Power    string                `json:"power"`
+	Orders   []GiveOrderPayload    `json:"orders"`
+	Notation []string              `json:"notation"`
+	Retreats []RetreatOrderPayload `json:"retreats"`
+	Builds   []BuildArmyPayload    `json:"builds"`
+	Ready    bool                  `json:"ready"`
+}
+
+// handleSubmitOrders validates the whole batch before storing any of it, a single invalid order
+// rejects the input and leaves the power's previous orders in place
+// Once valid, the batch replaces the orders the power gave earlier in the phase
+func (a *Game) handleSubmitOrders(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	inputPayload SubmitOrdersPayload,
+) error {
+	team, err := a.sender(metadata.MsgSender, inputPayload.Power)
+	if err != nil {
+		return err
+	}
+
+	for _, notation := range inputPayload.Notation {
+		order, err := a.parseOrder(notation)
+		if err != nil {
+			return err
+		}
+		inputPayload.Orders = append(inputPayload.Orders, order)
+	}
+	orders := map[int]Orders{}
+	for _, order := range inputPayload.Orders {
+		unit, validated, err := a.moveOrder(metadata.MsgSender, order)
+		if err != nil {
+			return fmt.Errorf("invalid order for unit %v: %w", order.UnitID, err)
+		}
+		err = a.checkBatchUnit(team, unit, orders)
+		if err != nil {
+			return err
+		}
+		orders[unit.ID] = validated
+	}
+	for _, retreat := range inputPayload.Retreats {
+		unit, validated, err := a.retreatOrder(metadata.MsgSender, retreat)
+		if err != nil {
+			return fmt.Errorf("invalid retreat for unit %v: %w", retreat.UnitID, err)
+		}
+		if unit.Retreating == "" {
+			return fmt.Errorf("unit %v is not retreating", unit.ID)
+		}
+		err = a.checkBatchUnit(team, unit, orders)
+		if err != nil {
+			return err
+		}
+		orders[unit.ID] = validated
+	}
+	builds := []*BuildArmyInput{}
+	units := len(team.Armies)
+	disbanded := map[int]bool{}
+	for _, build := range inputPayload.Builds {
+		if build.Owner == "" {
+			build.Owner = team.Name
+		}
+		if build.Owner != team.Name {
+			return fmt.Errorf("orders must all be given to units of %v", team.Name)
+		}
+		_, validated, err := a.buildOrder(metadata.MsgSender, build)
+		if err != nil {
+			return fmt.Errorf("invalid build in %v: %w", build.Position, err)
+		}
+		if disbanded[validated.Info.Delete] {
+			return fmt.Errorf("unit %v ordered twice", validated.Info.Delete)
+		}
+		for _, other := range builds {
+			if other.Info.Position == validated.Info.Position {
+				return fmt.Errorf("%v ordered twice", validated.Info.Position)
+			}
+		}
+		if validated.Info.Delete == 0 {
+			units++
+		} else {
+			disbanded[validated.Info.Delete] = true
+			units--
+		}
+		builds = append(builds, validated)
+	}
+	if units > team.Bases {
+		return fmt.Errorf("cant build another army without extra supply centers")
+	}
+
+	// the batch is valid, it replaces what the power ordered before
+	switch a.state.Turn {
+	case "move":
+		for _, unit := range a.state.Units {
+			if unit.Power == team.Name {
+				unit.CurrentOrder = Orders{UnitID: unit.ID, Ordertype: "hold"}
+			}
+		}
+		team.Submitted = true
+	case "retreats":
+		for _, unit := range a.state.Units {
+			if unit.Power == team.Name && unit.Retreating != "" {
+				unit.CurrentOrder = Orders{UnitID: unit.ID, Ordertype: "delete"}
+			}
+		}
+	case "build":
+		team.Builds = builds
+	}
+	for id, order := range orders {
+		a.state.Units[id].CurrentOrder = order
+	}
+
+	if inputPayload.Ready {
+		return a.ReadyOrders(env, metadata)
+	}
+	return nil
+}
+
+// checkBatchUnit makes sure an order of the batch is for a unit of the power that wasn't ordered
+// earlier in the batch
+func (a *Game) checkBatchUnit(team *Team, unit *Unit, orders map[int]Orders) error {
+	if unit.Power != team.Name {
+		return fmt.Errorf("orders must all be given to units of %v", team.Name)
+	}
+	if _, ok := orders[unit.ID]; ok {
+		return fmt.Errorf("unit %v ordered twice", unit.ID)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import "fmt"
+
+// Seasons of the game calendar
+const (
+	Spring = "spring"
+	Fall   = "fall"
+	Winter = "winter"
+)
+
+// PhaseInfo describes where the game currently is in the calendar
+type PhaseInfo struct {
+	Year   int    `json:"year"`
+	Season string `json:"season"`
+	Turn   string `json:"turn"`
+	Label  string `json:"label"`
+}
+
+// Season derives the season from the turn type and the move counter
+// MoveCounter is set after the spring movement and cleared after the fall one,
+// so a retreat phase with the counter set follows a spring movement
+func (g *GameState) Season() string {
+	switch g.Turn {
+	case "move":
+		if g.MoveCounter {
+			return Fall
+		}
+		return Spring
+	case "retreats":
+		if g.MoveCounter {
+			return Spring
+		}
+		return Fall
+	default:
+		return Winter
+	}
+}
+
+// PhaseLabel returns the phase in the usual short notation, e.g. S1901M, F1901R or W1901A
+func (g *GameState) PhaseLabel() string {
+	season := map[string]string{Spring: "S", Fall: "F", Winter: "W"}[g.Season()]
+	turn := map[string]string{"move": "M", "retreats": "R", "build": "A"}[g.Turn]
+	return fmt.Sprintf("%v%v%v", season, g.Year, turn)
+}
+
+func (g *GameState) Phase() PhaseInfo {
+	return PhaseInfo{
+		Year:   g.Year,
+		Season: g.Season(),
+		Turn:   g.Turn,
+		Label:  g.PhaseLabel(),
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rollmelette/rollmelette"
+)
+
+// Press modes a game can be created with, deciding which messages powers can exchange
+// Full press allows private and public messages, public press only messages to every power and
+// gunboat games allow no messages at all and keep the players anonymous until the game ends
+const (
+	FullPress    = "full"
+	PublicPress  = "public"
+	GunboatPress = "gunboat"
+)
+
+// Press mode used when the game creator doesn't pick one
+const DefaultPress = FullPress
+
+// Limits keeping the messages stored by a game bounded
+const (
+	MaxMessageLength    = 1000
+	MaxMessagesPerPhase = 20
+)
+
+// Message is a press message sent by a power, To is empty for messages sent to every power
+// Ciphertexts holds, instead of the Text, the message encrypted to each recipient's key
+// Phase is the label of the phase it was sent in and Timestamp the time of the block carrying it
+type Message struct {
+	ID          int               `json:"id"`
+	From        string            `json:"from"`
+	To          []string          `json:"to"`
+	Text        string            `json:"text,omitempty"`
+	Ciphertexts map[string][]byte `json:"ciphertexts,omitempty"`
+	Phase       string            `json:"phase"`
+	Timestamp   int64             `json:"timestamp"`
+}
+
+// SendMessagePayload is the payload for sending a press message
+// From is the power sending it, it can be left out by players controlling a single power
+// To lists the powers the message is meant to, the message is broadcast to every power when empty
+// Ciphertexts replaces the Text of a private message with a ciphertext per recipient, encrypted
+// off chain to the key the recipient registered, the application only stores and routes them
+// Inputs are public, so messages to some powers are only accepted encrypted
+type SendMessagePayload struct {
+	From        string            `json:"from"`
+	To          []string          `json:"to"`
+	Text        string            `json:"text"`
+	Ciphertexts map[string][]byte `json:"ciphertexts"`
+}
+
+// RegisterKeyPayload is the payload for registering the X25519 public key a power's messages
+// are encrypted to, Power can be left out by players controlling a single power
+type RegisterKeyPayload struct {
+	Power string `json:"power"`
+	Key   []byte `json:"key"`
+}
+
+func validPress(press string) error {
+	if press != FullPress && press != PublicPress && press != GunboatPress {
+		return fmt.Errorf("invalid press mode: %v", press)
+	}
+	return nil
+}
+
+func (a *Game) handleSendMessage(
+	metadata rollmelette.Metadata,
+	inputPayload SendMessagePayload,
+) error {
+	if a.Press == GunboatPress {
+		return fmt.Errorf("press is disabled in gunboat games")
+	}
+	if a.Press == PublicPress && len(inputPayload.To) != 0 {
+		return fmt.Errorf("only public press is allowed in this game")
+	}
+	team, err := a.sender(metadata.MsgSender, inputPayload.From)
+	if err != nil {
+		return err
+	}
+	for i, power := range inputPayload.To {
+		if _, ok := a.state.Players[power]; !ok {
+			return fmt.Errorf("power not found: %v", power)
+		}
+		if power == team.Name {
+			return fmt.Errorf("can't send a message to yourself")
+		}
+		if contains(inputPayload.To[:i], power) {
+			return fmt.Errorf("power listed twice: %v", power)
+		}
+	}
+	if inputPayload.Ciphertexts != nil {
+		err = a.checkCiphertexts(inputPayload)
+		if err != nil {
+			return err
+		}
+	} else if len(inputPayload.To) != 0 {
+		return fmt.Errorf("private messages must be encrypted")
+	} else if inputPayload.Text == "" {
+		return fmt.Errorf("can't send an empty message")
+	}
+	if len(inputPayload.Text) > MaxMessageLength {
+		return fmt.Errorf("message is longer than %v bytes", MaxMessageLength)
+	}
+	phase := a.state.PhaseLabel()
+	if a.sentIn(team.Name, phase) >= MaxMessagesPerPhase {
+		return fmt.Errorf("can't send more than %v messages per phase", MaxMessagesPerPhase)
+	}
+
+	a.state.Messages = append(a.state.Messages, &Message{
+		ID:          len(a.state.Messages) + 1,
+		From:        team.Name,
+		To:          inputPayload.To,
+		Text:        inputPayload.Text,
+		Ciphertexts: inputPayload.Ciphertexts,
+		Phase:       phase,
+		Timestamp:   metadata.BlockTimestamp,
+	})
+	return nil
+}
+
+// checkCiphertexts makes sure an encrypted message has a ciphertext for every recipient and
+// nothing else, and that every recipient has a key to decrypt it with
+func (a *Game) checkCiphertexts(inputPayload SendMessagePayload) error {
+	if inputPayload.Text != "" {
+		return fmt.Errorf("encrypted messages can't carry a plain text")
+	}
+	if len(inputPayload.To) == 0 {
+		return fmt.Errorf("encrypted messages need recipients")
+	}
+	if len(inputPayload.Ciphertexts) != len(inputPayload.To) {
+		return fmt.Errorf("encrypted messages need a ciphertext per recipient")
+	}
+	for _, power := range inputPayload.To {
+		ciphertext, ok := inputPayload.Ciphertexts[power]
+		if !ok {
+			return fmt.Errorf("missing ciphertext for %v", power)
+		}
+		if len(ciphertext) == 0 {
+			return fmt.Errorf("can't send an empty message")
+		}
+		if len(ciphertext) > MaxMessageLength {
+			return fmt.Errorf("message is longer than %v bytes", MaxMessageLength)
+		}
+		if a.state.Players[power].EncryptionKey == nil {
+			return fmt.Errorf("%v has no encryption key registered", power)
+		}
+	}
+	return nil
+}
+
+func (a *Game) handleRegisterKey(
+	metadata rollmelette.Metadata,
+	inputPayload RegisterKeyPayload,
+) error {
+	team, err := a.sender(metadata.MsgSender, inputPayload.Power)
+	if err != nil {
+		return err
+	}
+	_, err = ecdh.X25519().NewPublicKey(inputPayload.Key)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+	team.EncryptionKey = inputPayload.Key
+	return nil
+}
+
+// sentIn counts the messages the power sent during the phase
+func (a *Game) sentIn(power string, phase string) int {
+	sent := 0
+	for _, message := range a.state.Messages {
+		if message.From == power && message.Phase == phase {
+			sent++
+		}
+	}
+	return sent
+}
+
+// sender finds the team of the player an input is sent on behalf of, the power only needs to
+// be named when the player controls several
+func (a *Game) sender(player common.Address, power string) (*Team, error) {
+	teams := a.state.teamsOf(player)
+	if len(teams) == 0 {
+		return nil, fmt.Errorf("msg sender is not a player")
+	}
+	if power == "" {
+		if len(teams) > 1 {
+			return nil, fmt.Errorf("need to specify the power")
+		}
+		return teams[0], nil
+	}
+	for _, team := range teams {
+		if team.Name == power {
+			return team, nil
+		}
+	}
+	return nil, fmt.Errorf("msg sender doesn't play %v", power)
+}
+
+// queryMessages lists the messages of the game, only the ones of the given phase when there is one
+// Given a player, it only lists the broadcasts and the messages their powers sent or received
+// Messages are sent as inputs anyone can read, so the player only narrows the list down and keeps
+// nothing private, a message to some powers is only private when it is encrypted
+func queryMessages(a *Game, args QueryArgs) (any, error) {
+	powers := a.powersOf(args.Player)
+	messages := []*Message{}
+	for _, message := range a.state.Messages {
+		if args.Phase != "" && message.Phase != args.Phase {
+			continue
+		}
+		if args.Player == (common.Address{}) || message.readableBy(powers) {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// readableBy tells whether any of the powers sent or received the message
+func (m *Message) readableBy(powers []string) bool {
+	if len(m.To) == 0 || contains(powers, m.From) {
+		return true
+	}
+	for _, power := range m.To {
+		if contains(powers, power) {
+			return true
+		}
+	}
+	return false
+}
+
+// anonymous tells whether the players' addresses are kept hidden, gunboat games reveal them once
+// the game is over
+func (a *Game) anonymous() bool {
+	return a.Press == GunboatPress && a.Status != GameFinished
+}
+
+// publicView is the game as listed to everyone, the seats of an anonymous game are sorted so they
+// don't tell which power each player got
+func (a *Game) publicView() *Game {
+	if !a.anonymous() {
+		return a
+	}
+	game := *a
+	game.Seats = append([]common.Address{}, a.Seats...)
+	sort.Slice(game.Seats, func(i, j int) bool {
+		return bytes.Compare(game.Seats[i][:], game.Seats[j][:]) < 0
+	})
+	return &game
+}
+
+// drawPowers shuffles the seats of an anonymous game as it fills up, so the order the players
+// joined in doesn't tell which power each of them got
+// The draw is seeded by the input taking the last seat, every player's inputs still show which
+// power they play to anyone reading them on the base layer
+func (a *Game) drawPowers(metadata rollmelette.Metadata) {
+	hash := crypto.Keccak256([]byte(fmt.Sprintf(
+		"diplomacy game %d input %d block %d timestamp %d",
+		a.ID, metadata.InputIndex, metadata.BlockNumber, metadata.BlockTimestamp,
+	)))
+	rng := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(hash))))
+	rng.Shuffle(len(a.Seats), func(i, j int) {
+		a.Seats[i], a.Seats[j] = a.Seats[j], a.Seats[i]
+	})
+}
+
+// anonymousView hides from the view which address plays each power, hidden seats are flagged so
+// they aren't mistaken for powers in civil disorder
+func anonymousView(view *GameState) {
+	for _, team := range view.Players {
+		if team.inCivilDisorder() {
+			continue
+		}
+		team.Player = common.Address{}
+		team.Hidden = true
+		builds := make([]*BuildArmyInput, len(team.Builds))
+		for i, build := range team.Builds {
+			builds[i] = &BuildArmyInput{Info: build.Info}
+		}
+		team.Builds = builds
+	}
+	for _, unit := range view.Units {
+		unit.Owner = common.Address{}
+	}
+}
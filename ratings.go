@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Rating every player starts with and how much a single game can move it
+const (
+	InitialRating = 1500.0
+	RatingFactor  = 32.0
+)
+
+// PlayerProfile is a player's record across every game played in the application
+// Phases counts the movement phases played and NMRs the ones where no order was submitted
+type PlayerProfile struct {
+	Player       common.Address `json:"player"`
+	Rating       float64        `json:"rating"`
+	Games        int            `json:"games"`
+	Wins         int            `json:"wins"`
+	Draws        int            `json:"draws"`
+	Eliminations int            `json:"eliminations"`
+	Phases       int            `json:"phases"`
+	NMRs         int            `json:"nmrs"`
+}
+
+// Reliability is the share of movement phases where the player submitted orders
+func (p *PlayerProfile) Reliability() float64 {
+	if p.Phases == 0 {
+		return 1
+	}
+	return 1 - float64(p.NMRs)/float64(p.Phases)
+}
+
+func (p *PlayerProfile) MarshalJSON() ([]byte, error) {
+	type profile PlayerProfile
+	return json.Marshal(struct {
+		*profile
+		Reliability float64 `json:"reliability"`
+	}{(*profile)(p), p.Reliability()})
+}
+
+// rating is the player's current rating, players who never finished a game have the initial rating
+func (a *GameApplication) rating(player common.Address) float64 {
+	profile, ok := a.profiles[player]
+	if !ok {
+		return InitialRating
+	}
+	return profile.Rating
+}
+
+func (a *GameApplication) profile(player common.Address) *PlayerProfile {
+	profile, ok := a.profiles[player]
+	if !ok {
+		profile = &PlayerProfile{Player: player, Rating: InitialRating}
+		a.profiles[player] = profile
+	}
+	return profile
+}
+
+// updateRatings records the game in each player's profile and updates the ratings with a
+// multiplayer Elo, where every pair of players is a match won by the one with the higher score
+// Players controlling several powers are scored with the sum of their powers' scores
+func (a *GameApplication) updateRatings(game *Game) {
+	type seat struct {
+		profile *PlayerProfile
+		teams   []*Team
+		score   float64
+	}
+	var seats []seat
+	for _, player := range game.Seats {
+		teams := game.state.teamsOf(player)
+		score := 0.0
+		for _, team := range teams {
+			score += game.Scores[team.Name]
+		}
+		seats = append(seats, seat{profile: a.profile(player), teams: teams, score: score})
+	}
+
+	deltas := make([]float64, len(seats))
+	for i := range seats {
+		for j := range seats {
+			if i == j {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (seats[j].profile.Rating-seats[i].profile.Rating)/400))
+			actual := 0.5
+			if seats[i].score > seats[j].score {
+				actual = 1
+			} else if seats[i].score < seats[j].score {
+				actual = 0
+			}
+			deltas[i] += RatingFactor / float64(len(seats)-1) * (actual - expected)
+		}
+	}
+
+	for i, seat := range seats {
+		profile := seat.profile
+		profile.Rating += deltas[i]
+		profile.Games++
+		won, drawn, surviving := false, false, false
+		for _, team := range seat.teams {
+			profile.Phases += team.Phases
+			profile.NMRs += team.NMRs
+			won = won || game.Result.Winner == team.Name
+			drawn = drawn || contains(game.Result.Draw, team.Name)
+			surviving = surviving || game.surviving(team)
+		}
+		switch {
+		case won:
+			profile.Wins++
+		case drawn:
+			profile.Draws++
+		case !surviving:
+			profile.Eliminations++
+		}
+	}
+}
+
+// recordSubmissions counts a movement phase for every power with units, and an NMR for the ones
+// that didn't submit any order
+func (a *Game) recordSubmissions() {
+	for _, team := range a.state.Players {
+		if a.unitCount(team) > 0 {
+			team.Phases++
+			if !team.Submitted {
+				team.NMRs++
+			}
+		}
+		team.Submitted = false
+	}
+}
+
+func (a *Game) unitCount(team *Team) int {
+	count := 0
+	for _, unit := range a.state.Units {
+		if unit.Power == team.Name {
+			count++
+		}
+	}
+	return count
+}
+
+func (a *GameApplication) leaderboard() []*PlayerProfile {
+	profiles := make([]*PlayerProfile, 0, len(a.profiles))
+	for _, profile := range a.profiles {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Rating != profiles[j].Rating {
+			return profiles[i].Rating > profiles[j].Rating
+		}
+		return profiles[i].Player.Hex() < profiles[j].Player.Hex()
+	})
+	return profiles
+}
+
+func queryLeaderboard(a *GameApplication, args QueryArgs) (any, error) {
+	return a.leaderboard(), nil
+}
+
+func queryProfile(a *GameApplication, args QueryArgs) (any, error) {
+	profile, ok := a.profiles[args.Player]
+	if !ok {
+		return nil, fmt.Errorf("player not found: %v", args.Player)
+	}
+	return profile, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
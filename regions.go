@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// goesBy tells whether the region is called the normalized name, by its name, its abbreviation or
+// one of its aliases
+func (r *Region) goesBy(name string) bool {
+	if normalizeName(r.Name) == name || normalizeName(r.Abbreviation) == name {
+		return true
+	}
+	for _, alias := range r.Aliases {
+		if normalizeName(alias) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRegion finds the region a player refers to, by its name, abbreviation or an alias,
+// ignoring case, spaces and punctuation
+// Every region name coming from an input or a query goes through it before the board is read
+func (a *Game) resolveRegion(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("need to specify the region")
+	}
+	normalized := normalizeName(name)
+	for _, region := range a.state.Board {
+		if region.goesBy(normalized) {
+			return region.Name, nil
+		}
+	}
+	return "", fmt.Errorf("region not found: %v", name)
+}
+
+// resolveLocation finds the region and the coast of a location, the coast is written after a
+// slash or in parentheses, e.g. Spain/NC or spa(nc)
+func (a *Game) resolveLocation(location string) (string, string, error) {
+	name, coast, _ := strings.Cut(location, "/")
+	if before, after, ok := strings.Cut(location, "("); ok {
+		name, coast = before, strings.TrimSuffix(after, ")")
+	}
+	region, err := a.resolveRegion(name)
+	if err != nil {
+		return "", "", err
+	}
+	return region, strings.ToUpper(strings.TrimSpace(coast)), nil
+}
+
+// resolveDestination resolves the region an order is given to along with its coast, which can be
+// part of the region's name or given on its own
+func (a *Game) resolveDestination(location string, coast string) (string, string, error) {
+	region, named, err := a.resolveLocation(location)
+	if err != nil {
+		return "", "", err
+	}
+	coast = strings.ToUpper(coast)
+	if named != "" && coast != "" && named != coast {
+		return "", "", fmt.Errorf("conflicting coasts: %v and %v", named, coast)
+	}
+	if named != "" {
+		coast = named
+	}
+	return region, coast, nil
+}
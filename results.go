@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rollmelette/rollmelette"
+)
+
+type NoticeKind string
+
+// Notice kinds emitted
+const (
+	PhaseResultNotice NoticeKind = "PhaseResult"
+	GameEndNotice     NoticeKind = "GameEnd"
+
+	TournamentEndNotice NoticeKind = "TournamentEnd"
+)
+
+// Notice is the envelope of every notice emitted by the application
+type Notice struct {
+	Kind    NoticeKind `json:"kind"`
+	Payload any        `json:"payload"`
+}
+
+// Results an order can have once the phase is adjudicated
+const (
+	ResultSucceeded = "succeeded"
+	ResultBounced   = "bounced"
+	ResultFailed    = "failed"
+	ResultDislodged = "dislodged"
+	ResultDisbanded = "disbanded"
+)
+
+// OrderResult is the outcome of a single unit's order, Notation is the order in standard notation
+type OrderResult struct {
+	Order    Orders `json:"order"`
+	Notation string `json:"notation"`
+	Power    string `json:"power"`
+	Result   string `json:"result"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Dislodgement records a unit forced out of its region, it must retreat in the next phase
+type Dislodgement struct {
+	UnitID       int    `json:"unitID"`
+	Power        string `json:"power"`
+	Region       string `json:"region"`
+	AttackedFrom string `json:"attackedFrom"`
+}
+
+// CenterChange records a supply center changing hands
+type CenterChange struct {
+	Region string `json:"region"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// PhaseResult is the log of a phase resolution
+// Player is who the result was filtered for in a fog of war game, it is left out of public results
+type PhaseResult struct {
+	Phase         string            `json:"phase"`
+	Player        *common.Address   `json:"player,omitempty"`
+	Orders        []OrderResult     `json:"orders"`
+	Builds        []*BuildArmyInput `json:"builds"`
+	Dislodged     []Dislodgement    `json:"dislodged"`
+	CenterChanges []CenterChange    `json:"centerChanges"`
+}
+
+// phaseSnapshot keeps what adjudication overwrites so the results can be worked out afterwards
+type phaseSnapshot struct {
+	turn           string
+	units          map[int]Unit
+	centers        map[string]string
+	builds         []*BuildArmyInput
+	convoyFailures map[int]string
+}
+
+func emitNotice(env rollmelette.Env, kind NoticeKind, payload any) error {
+	bytes, err := json.Marshal(Notice{Kind: kind, Payload: payload})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notice: %w", err)
+	}
+	env.Notice(bytes)
+	return nil
+}
+
+func (a *Game) takeSnapshot() phaseSnapshot {
+	snapshot := phaseSnapshot{
+		turn:           a.state.Turn,
+		units:          make(map[int]Unit, len(a.state.Units)),
+		centers:        make(map[string]string),
+		convoyFailures: make(map[int]string),
+	}
+	for id, unit := range a.state.Units {
+		snapshot.units[id] = *unit
+		if unit.CurrentOrder.Ordertype == "convoy move" {
+			snapshot.convoyFailures[id] = a.convoyFailure(unit)
+		}
+	}
+	for name, region := range a.state.Board {
+		if region.SupplyCenter {
+			snapshot.centers[name] = region.Owner
+		}
+	}
+	for _, player := range a.state.Players {
+		snapshot.builds = append(snapshot.builds, player.Builds...)
+	}
+	return snapshot
+}
+
+// phaseResult compares the snapshot taken before adjudication with the current state
+func (a *Game) phaseResult(label string, snapshot phaseSnapshot) PhaseResult {
+	result := PhaseResult{
+		Phase:         label,
+		Orders:        []OrderResult{},
+		Builds:        []*BuildArmyInput{},
+		Dislodged:     []Dislodgement{},
+		CenterChanges: []CenterChange{},
+	}
+
+	ids := make([]int, 0, len(snapshot.units))
+	for id := range snapshot.units {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		before := snapshot.units[id]
+		after, exists := a.state.Units[id]
+		if snapshot.turn == "build" || (snapshot.turn == "retreats" && before.Retreating == "") {
+			continue
+		}
+		orderResult := OrderResult{
+			Order:    before.CurrentOrder,
+			Notation: a.formatOrder(before, snapshot.units),
+			Power:    before.Power,
+			Result:   ResultSucceeded,
+		}
+
+		switch {
+		case snapshot.turn == "retreats":
+			if !exists {
+				orderResult.Result = ResultDisbanded
+				if before.CurrentOrder.Ordertype == "move" {
+					orderResult.Reason = "retreat bounced"
+				}
+			}
+		case after.Retreating != "":
+			orderResult.Result = ResultDislodged
+			result.Dislodged = append(result.Dislodged, Dislodgement{
+				UnitID:       id,
+				Power:        orderResult.Power,
+				Region:       after.Position,
+				AttackedFrom: after.Retreating,
+			})
+		case before.CurrentOrder.Ordertype == "move" && after.Position != before.CurrentOrder.ToRegion:
+			orderResult.Result = ResultBounced
+		case before.CurrentOrder.Ordertype == "convoy move" && after.Position != before.CurrentOrder.ToRegion:
+			orderResult.Result = ResultFailed
+			orderResult.Reason = snapshot.convoyFailures[id]
+			if orderResult.Reason == "" {
+				orderResult.Result = ResultBounced
+			}
+		}
+		result.Orders = append(result.Orders, orderResult)
+	}
+
+	if snapshot.turn == "build" {
+		for _, build := range snapshot.builds {
+			// the power building is in the order already, anonymous games leave the player out
+			if a.anonymous() {
+				build = &BuildArmyInput{Info: build.Info}
+			}
+			result.Builds = append(result.Builds, build)
+		}
+	}
+
+	for _, name := range sortedKeys(snapshot.centers) {
+		owner := a.state.Board[name].Owner
+		if owner != snapshot.centers[name] {
+			result.CenterChanges = append(result.CenterChanges, CenterChange{
+				Region: name,
+				From:   snapshot.centers[name],
+				To:     owner,
+			})
+		}
+	}
+	return result
+}
@@ -3,31 +3,49 @@ package main
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/rollmelette/rollmelette"
 )
 
-func (a *GameApplication) handleRetreat(
+func (a *Game) handleRetreat(
 	metadata rollmelette.Metadata,
 	inputPayload RetreatOrderPayload,
 ) error {
+	unit, orders, err := a.retreatOrder(metadata.MsgSender, inputPayload)
+	if err != nil {
+		return err
+	}
+	unit.CurrentOrder = orders
+	return nil
+}
 
+// retreatOrder checks the player can give the retreat order to the unit and returns the order
+// to store without storing it
+func (a *Game) retreatOrder(player common.Address, inputPayload RetreatOrderPayload) (*Unit, Orders, error) {
 	if a.state.Turn != "retreats" {
-		return fmt.Errorf("can't issue a retreat order outside retreating phase")
+		return nil, Orders{}, fmt.Errorf("can't issue a retreat order outside retreating phase")
 	}
 	unit, ok := a.state.Units[inputPayload.UnitID]
 	if !ok {
-		return fmt.Errorf("unit not found")
+		return nil, Orders{}, fmt.Errorf("unit not found")
 	}
-	if metadata.MsgSender != unit.Owner {
-		return fmt.Errorf("can't retreat another player's unit")
+	if a.state.Players[unit.Power].Player != player {
+		return nil, Orders{}, fmt.Errorf("can't retreat another player's unit")
+	}
+	if !inputPayload.Delete {
+		var err error
+		inputPayload.ToRegion, inputPayload.ToSubRegion, err = a.resolveDestination(inputPayload.ToRegion, inputPayload.ToSubRegion)
+		if err != nil {
+			return nil, Orders{}, err
+		}
 	}
 
 	// Check if the target region is the current position or the forward (defeated from) position
 	if inputPayload.ToRegion == unit.Position {
-		return fmt.Errorf("can't retreat to the same place")
+		return nil, Orders{}, fmt.Errorf("can't retreat to the same place")
 	}
 	if inputPayload.ToRegion == unit.Retreating {
-		return fmt.Errorf("can't retreat forward to the attacking region")
+		return nil, Orders{}, fmt.Errorf("can't retreat forward to the attacking region")
 	}
 
 	orderType := "move"
@@ -41,13 +59,18 @@ func (a *GameApplication) handleRetreat(
 
 	if orderType == "move" {
 		if a.state.Board[inputPayload.ToRegion].Occupied {
-			return fmt.Errorf("can't retreat to an occupied region")
+			return nil, Orders{}, fmt.Errorf("can't retreat to an occupied region")
 		}
 
 		// Check if the retreating region is connected
-		if !isConnected(a.state.Board[unit.Position], &inputPayload.ToRegion) {
-			return fmt.Errorf("can't retreat to non-adjacent region")
+		if !isReachable(a.state.Board[unit.Position], unit.SubPosition, unit.Type, inputPayload.ToRegion) {
+			return nil, Orders{}, fmt.Errorf("can't retreat to non-adjacent region")
+		}
+		toCoast, err := a.checkCoasts(unit, "", inputPayload.ToRegion, inputPayload.ToSubRegion)
+		if err != nil {
+			return nil, Orders{}, err
 		}
+		inputPayload.ToSubRegion = toCoast
 	}
 
 	orders := Orders{
@@ -56,16 +79,10 @@ func (a *GameApplication) handleRetreat(
 		ToRegion:    inputPayload.ToRegion,
 		ToSubRegion: inputPayload.ToSubRegion,
 	}
-	fmt.Println(orders)
-
-	a.state.Units[inputPayload.UnitID].CurrentOrder = orders
-
-	fmt.Println(a.state.Units[inputPayload.UnitID].CurrentOrder)
-
-	return nil
+	return unit, orders, nil
 }
 
-func resolveRetreats(a *GameApplication) {
+func resolveRetreats(a *Game) {
 	// Iterate through the units and handle their retreat orders
 	for _, unit := range a.state.Units {
 	outerSwitch:
@@ -74,10 +91,8 @@ func resolveRetreats(a *GameApplication) {
 			// Do nothing for hold orders
 			continue
 		case "delete":
-			fmt.Println("Deleting unit:", unit.ID)
-
 			// Delete unit from player's armies
-			delete(a.state.Players[unit.Owner].Armies, unit.ID)
+			delete(a.state.Players[unit.Power].Armies, unit.ID)
 
 			// Delete unit from the game's state
 			delete(a.state.Units, unit.ID)
@@ -87,8 +102,8 @@ func resolveRetreats(a *GameApplication) {
 			for _, other := range a.state.Units {
 				//both retreating units tryed to move to the same place and are deleted instead
 				if other.CurrentOrder.Ordertype == "move" && unit.ID != other.ID {
-					delete(a.state.Players[unit.Owner].Armies, unit.ID)
-					delete(a.state.Players[unit.Owner].Armies, other.ID)
+					delete(a.state.Players[unit.Power].Armies, unit.ID)
+					delete(a.state.Players[other.Power].Armies, other.ID)
 					delete(a.state.Units, unit.ID)
 					delete(a.state.Units, other.ID)
 					break outerSwitch
@@ -105,15 +120,12 @@ func resolveRetreats(a *GameApplication) {
 
 				// Update Unit's SubPosition if needed
 				unit.SubPosition = unit.CurrentOrder.ToSubRegion
-
-			} else {
-				fmt.Println("Failed to move unit", unit.ID, "to occupied region", unit.CurrentOrder.ToRegion)
 			}
 		}
 	}
 }
 
-func setForDelete(a *GameApplication) {
+func setForDelete(a *Game) {
 	for _, unit := range a.state.Units {
 		if unit.Retreating != "" {
 			unit.CurrentOrder.Ordertype = "delete"
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// ScoringSystem turns the final supply center count of every power and the way the game ended
+// into a score per power, scores are used to split the pot and to update ratings
+// supplyCenters is the number of supply centers on the map, centers has an entry for every power
+// Scores are never negative
+type ScoringSystem interface {
+	Score(centers map[string]int, supplyCenters int, result *GameResult) map[string]float64
+}
+
+// Scoring systems a game can be created with
+var scoringSystems = map[string]ScoringSystem{
+	"dss":     DrawSizeScoring{},
+	"sos":     SumOfSquaresScoring{},
+	"carnage": CarnageScoring{},
+	"cdiplo":  CDiploScoring{},
+}
+
+// Scoring system used when the game creator doesn't pick one
+const DefaultScoring = "dss"
+
+func scoringSystem(name string) (ScoringSystem, error) {
+	if name == "" {
+		name = DefaultScoring
+	}
+	system, ok := scoringSystems[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid scoring system: %v", name)
+	}
+	return system, nil
+}
+
+// soloScore gives everything to the winner, every system scores a solo the same way
+func soloScore(centers map[string]int, winner string, points float64) map[string]float64 {
+	scores := make(map[string]float64, len(centers))
+	for power := range centers {
+		scores[power] = 0
+	}
+	scores[winner] = points
+	return scores
+}
+
+// drawCenters keeps the centers of the powers included in the draw, the powers left out of it
+// score nothing whatever centers they hold
+func drawCenters(centers map[string]int, result *GameResult) map[string]int {
+	if len(result.Draw) == 0 {
+		return centers
+	}
+	counted := make(map[string]int, len(result.Draw))
+	for _, power := range result.Draw {
+		counted[power] = centers[power]
+	}
+	return counted
+}
+
+// DrawSizeScoring splits 100 points evenly between the powers included in the draw
+type DrawSizeScoring struct{}
+
+func (DrawSizeScoring) Score(centers map[string]int, supplyCenters int, result *GameResult) map[string]float64 {
+	if result.Winner != "" {
+		return soloScore(centers, result.Winner, 100)
+	}
+	scores := make(map[string]float64, len(centers))
+	for power := range centers {
+		scores[power] = 0
+	}
+	for _, power := range result.Draw {
+		scores[power] = 100 / float64(len(result.Draw))
+	}
+	return scores
+}
+
+// SumOfSquaresScoring gives each power in the draw 100 points times the square of its centers over
+// the sum of the squares of the centers of every power in the draw
+type SumOfSquaresScoring struct{}
+
+func (SumOfSquaresScoring) Score(centers map[string]int, supplyCenters int, result *GameResult) map[string]float64 {
+	if result.Winner != "" {
+		return soloScore(centers, result.Winner, 100)
+	}
+	counted := drawCenters(centers, result)
+	sum := 0
+	for _, count := range counted {
+		sum += count * count
+	}
+	scores := make(map[string]float64, len(centers))
+	for power := range centers {
+		scores[power] = 0
+	}
+	for power, count := range counted {
+		if sum > 0 {
+			scores[power] = 100 * float64(count*count) / float64(sum)
+		}
+	}
+	return scores
+}
+
+// CarnageScoring ranks the powers in the draw by centers, with n powers in the game the first place
+// gets n*1000 points, the second (n-1)*1000 and so on, plus one point per center. Tied powers share
+// the points of the places they occupy, eliminated powers and powers left out of the draw get
+// nothing. A solo winner takes every point available
+type CarnageScoring struct{}
+
+func (CarnageScoring) Score(centers map[string]int, supplyCenters int, result *GameResult) map[string]float64 {
+	n := len(centers)
+	if result.Winner != "" {
+		return soloScore(centers, result.Winner, float64(1000*n*(n+1)/2+supplyCenters))
+	}
+	counted := drawCenters(centers, result)
+	powers := sortedKeys(counted)
+	sort.SliceStable(powers, func(i, j int) bool {
+		return counted[powers[i]] > counted[powers[j]]
+	})
+
+	scores := make(map[string]float64, len(centers))
+	for power := range centers {
+		scores[power] = 0
+	}
+	for i := 0; i < len(powers); {
+		// group the powers tied with the one in place i
+		j := i
+		rankPoints := 0.0
+		for j < len(powers) && counted[powers[j]] == counted[powers[i]] {
+			rankPoints += float64(1000 * (n - j))
+			j++
+		}
+		for _, power := range powers[i:j] {
+			if counted[power] > 0 {
+				scores[power] = math.Max(0, rankPoints/float64(j-i)+float64(counted[power]))
+			}
+		}
+		i = j
+	}
+	return scores
+}
+
+// CDiploScoring gives every power in the draw one point for playing and one per center, and 38
+// extra points to the power in the draw with most centers, split between the powers tied at the top
+type CDiploScoring struct{}
+
+func (CDiploScoring) Score(centers map[string]int, supplyCenters int, result *GameResult) map[string]float64 {
+	if result.Winner != "" {
+		return soloScore(centers, result.Winner, float64(1+supplyCenters+38))
+	}
+	counted := drawCenters(centers, result)
+	top := 0
+	for _, count := range counted {
+		if count > top {
+			top = count
+		}
+	}
+	toppers := 0
+	for _, count := range counted {
+		if count == top {
+			toppers++
+		}
+	}
+	scores := make(map[string]float64, len(centers))
+	for power := range centers {
+		scores[power] = 0
+	}
+	for power, count := range counted {
+		scores[power] = 1 + float64(count)
+		if count == top {
+			scores[power] += 38 / float64(toppers)
+		}
+	}
+	return scores
+}
+
+// scorePayouts splits the pot proportionally to the scores, rounding down, and hands whatever is
+// left over to the highest scoring power
+// Negative scores count as zero so no power is ever asked to pay into the pot
+func scorePayouts(pot *big.Int, scores map[string]float64) map[string]*big.Int {
+	powers := sortedKeys(scores)
+	positive := make(map[string]float64, len(scores))
+	total := new(big.Rat)
+	top := ""
+	for _, power := range powers {
+		positive[power] = math.Max(0, scores[power])
+		total.Add(total, new(big.Rat).SetFloat64(positive[power]))
+		if top == "" || positive[power] > positive[top] {
+			top = power
+		}
+	}
+
+	payouts := make(map[string]*big.Int, len(scores))
+	paid := new(big.Int)
+	for _, power := range powers {
+		amount := new(big.Int)
+		if total.Sign() > 0 {
+			share := new(big.Rat).SetFloat64(positive[power])
+			share.Mul(share, new(big.Rat).SetInt(pot))
+			share.Quo(share, total)
+			amount.Quo(share.Num(), share.Denom())
+		}
+		payouts[power] = amount
+		paid.Add(paid, amount)
+	}
+	if top != "" {
+		payouts[top].Add(payouts[top], new(big.Int).Sub(pot, paid))
+	}
+	return payouts
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rollmelette/rollmelette"
+)
+
+// Payout is an amount paid from a game's escrow to a player
+type Payout struct {
+	Player common.Address `json:"player"`
+	Power  string         `json:"power"`
+	Amount *big.Int       `json:"amount"`
+}
+
+// GameEnd is published when a game finishes and its pot is paid out
+type GameEnd struct {
+	GameID  int                `json:"gameID"`
+	Result  *GameResult        `json:"result"`
+	Scores  map[string]float64 `json:"scores"`
+	Payouts []Payout           `json:"payouts"`
+}
+
+// escrowAddress is the wallet account holding a game's entry fees until they are paid out
+func escrowAddress(gameID int) common.Address {
+	return common.BytesToAddress(crypto.Keccak256([]byte(fmt.Sprintf("diplomacy game escrow %d", gameID))))
+}
+
+// DepositRefused is reported when a deposit can't be used by the input it came with, the deposit
+// stays in the depositor's wallet until they withdraw it
+type DepositRefused struct {
+	Error string `json:"error"`
+}
+
+// WithdrawPayload is the payload for withdrawing the funds held for the sender in the application
+// wallet, in Ether when Token is empty
+type WithdrawPayload struct {
+	Token common.Address `json:"token"`
+}
+
+// handleDeposit handles the inputs sent through a portal, only joining a game takes a deposit
+// The portal credits the deposit to the depositor's wallet before the input is handled and
+// rejecting the input would revert the credit while the funds stay in the application contract,
+// so an input that can't use its deposit is accepted and the deposit left in the wallet
+func (a *GameApplication) handleDeposit(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	deposit rollmelette.Deposit,
+	payload []byte,
+) error {
+	game, err := a.joinWithDeposit(env, metadata, deposit, payload)
+	if err != nil {
+		return report(env, DepositRefused{Error: err.Error()})
+	}
+	return report(env, game.publicView())
+}
+
+func (a *GameApplication) joinWithDeposit(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	deposit rollmelette.Deposit,
+	payload []byte,
+) (*Game, error) {
+	var input Input
+	err := json.Unmarshal(payload, &input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal input: %w", err)
+	}
+	if input.Kind != JoinGame {
+		return nil, fmt.Errorf("deposits are only accepted to join a game")
+	}
+	return a.handleJoinGame(env, metadata, deposit, input.GameID)
+}
+
+// depositor is the address that made the deposit, the sender of a deposit input is the portal
+func depositor(metadata rollmelette.Metadata, deposit rollmelette.Deposit) common.Address {
+	switch deposit := deposit.(type) {
+	case *rollmelette.EtherDeposit:
+		return deposit.Sender
+	case *rollmelette.ERC20Deposit:
+		return deposit.Sender
+	}
+	return metadata.MsgSender
+}
+
+// collectEntryFee checks the deposit sent along with a join and moves it into the game's escrow
+func (a *GameApplication) collectEntryFee(
+	env rollmelette.Env,
+	game *Game,
+	player common.Address,
+	deposit rollmelette.Deposit,
+) error {
+	if game.EntryFee.Sign() == 0 {
+		if deposit != nil {
+			return fmt.Errorf("game has no entry fee")
+		}
+		return nil
+	}
+
+	var amount *big.Int
+	if game.Token == (common.Address{}) {
+		etherDeposit, ok := deposit.(*rollmelette.EtherDeposit)
+		if !ok {
+			return fmt.Errorf("game requires an Ether deposit of %v wei to join", game.EntryFee)
+		}
+		amount = etherDeposit.Value
+	} else {
+		erc20Deposit, ok := deposit.(*rollmelette.ERC20Deposit)
+		if !ok || erc20Deposit.Token != game.Token {
+			return fmt.Errorf("game requires a deposit of %v of token %v to join", game.EntryFee, game.Token)
+		}
+		amount = erc20Deposit.Amount
+	}
+	if amount.Cmp(game.EntryFee) != 0 {
+		return fmt.Errorf("deposit must be exactly the entry fee of %v", game.EntryFee)
+	}
+
+	err := transfer(env, game.Token, player, escrowAddress(game.ID), amount)
+	if err != nil {
+		return fmt.Errorf("failed to hold entry fee: %w", err)
+	}
+	game.Pot.Add(game.Pot, amount)
+	return nil
+}
+
+// handleWithdraw issues the voucher for every fund the sender holds in the application wallet,
+// deposits that couldn't be used are taken back this way
+func (a *GameApplication) handleWithdraw(
+	env rollmelette.Env,
+	metadata rollmelette.Metadata,
+	inputPayload WithdrawPayload,
+) (*Payout, error) {
+	var balance *big.Int
+	if inputPayload.Token == (common.Address{}) {
+		balance = env.EtherBalanceOf(metadata.MsgSender)
+	} else {
+		balance = env.ERC20BalanceOf(inputPayload.Token, metadata.MsgSender)
+	}
+	if balance.Sign() <= 0 {
+		return nil, fmt.Errorf("nothing to withdraw")
+	}
+	err := withdraw(env, inputPayload.Token, metadata.MsgSender, balance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to withdraw: %w", err)
+	}
+	return &Payout{Player: metadata.MsgSender, Amount: balance}, nil
+}
+
+// settleGame finishes the game, scores it and pays the pot out proportionally to the scores
+func (a *GameApplication) settleGame(env rollmelette.Env, game *Game) error {
+	game.Status = GameFinished
+
+	system, err := scoringSystem(game.Scoring)
+	if err != nil {
+		return err
+	}
+	game.Scores = system.Score(game.centers(), game.state.supplyCenters(), game.Result)
+	a.updateRatings(game)
+
+	var payouts []Payout
+	if game.Pot.Sign() > 0 {
+		amounts := scorePayouts(game.Pot, game.seatedScores())
+		for _, power := range sortedKeys(amounts) {
+			payouts = append(payouts, Payout{Player: game.seatOf(power), Power: power, Amount: amounts[power]})
+		}
+	}
+
+	err = a.payOut(env, game, payouts)
+	if err != nil {
+		return err
+	}
+	return emitNotice(env, GameEndNotice, GameEnd{
+		GameID:  game.ID,
+		Result:  game.Result,
+		Scores:  game.Scores,
+		Payouts: payouts,
+	})
+}
+
+// seatedScores leaves out the powers in civil disorder, nobody collects their share of the pot
+func (a *Game) seatedScores() map[string]float64 {
+	scores := make(map[string]float64, len(a.Scores))
+	for power, score := range a.Scores {
+		if team, ok := a.state.Players[power]; ok && !team.inCivilDisorder() {
+			scores[power] = score
+		}
+	}
+	return scores
+}
+
+// payOut moves each amount from the game's escrow to the player and issues the withdrawal voucher
+func (a *GameApplication) payOut(env rollmelette.Env, game *Game, payouts []Payout) error {
+	for _, payout := range payouts {
+		if payout.Amount.Sign() < 0 {
+			return fmt.Errorf("invalid payout of %v to %v", payout.Amount, payout.Player)
+		}
+		if payout.Amount.Sign() == 0 {
+			continue
+		}
+		err := transfer(env, game.Token, escrowAddress(game.ID), payout.Player, payout.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to pay out: %w", err)
+		}
+		err = withdraw(env, game.Token, payout.Player, payout.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to withdraw: %w", err)
+		}
+		game.Pot.Sub(game.Pot, payout.Amount)
+	}
+	return nil
+}
+
+// transfer moves funds inside the application wallet, in Ether when the token is the zero address
+func transfer(env rollmelette.Env, token common.Address, src common.Address, dst common.Address, value *big.Int) error {
+	if token == (common.Address{}) {
+		return env.EtherTransfer(src, dst, value)
+	}
+	return env.ERC20Transfer(token, src, dst, value)
+}
+
+// withdraw issues the voucher taking the funds out of the application wallet
+func withdraw(env rollmelette.Env, token common.Address, address common.Address, value *big.Int) error {
+	var err error
+	if token == (common.Address{}) {
+		_, err = env.EtherWithdraw(address, value)
+	} else {
+		_, err = env.ERC20Withdraw(token, address, value)
+	}
+	return err
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/rollmelette/rollmelette"
+)
+
+type TournamentStatus string
+
+// Tournament lifecycle, registration closes once the first round starts
+const (
+	TournamentOpen     TournamentStatus = "open"
+	TournamentRunning  TournamentStatus = "running"
+	TournamentFinished TournamentStatus = "finished"
+)
+
+// Ways of seeding the boards of a round
+const (
+	RandomSeeding = "random"
+	RatingSeeding = "rating"
+)
+
+// Tournament plays its registered players over a number of rounds, every round seats them on
+// boards of seven and a player's standing is the sum of the scores of every board played
+// Boards lists the IDs of the games of each round started so far, all played with the Variant
+// SeedHash is the hash the organizer committed to when creating the tournament, Seed the seed
+// revealed with the first round and Closing the hash of the block data of that input, which closes
+// registration, every round's draw comes from both
+// Tournament IDs start at 1, games that are not part of a tournament have tournament 0
+type Tournament struct {
+	ID        int              `json:"id"`
+	Status    TournamentStatus `json:"status"`
+	Organizer common.Address   `json:"organizer"`
+	Rounds    int              `json:"rounds"`
+	Seeding   string           `json:"seeding"`
+	Scoring   string           `json:"scoring"`
+	RoundTime int              `json:"roundTime"`
+	Variant   string           `json:"variant"`
+	SeedHash  common.Hash      `json:"seedHash"`
+	Seed      []byte           `json:"seed,omitempty"`
+	Closing   common.Hash      `json:"closing"`
+	Players   []common.Address `json:"players"`
+	Boards    [][]int          `json:"boards"`
+}
+
+// CreateTournamentPayload is the payload for creating a tournament
+// Seeding is how boards are drawn each round: random (default) or rating, which spreads the
+// players evenly across boards by rating
+// Scoring is the scoring system every board of the tournament uses
+// SeedHash is the Keccak-256 hash of a secret seed the organizer reveals to start the first round,
+// committing to it before anyone registers keeps the organizer from choosing it once the entrants
+// are known
+type CreateTournamentPayload struct {
+	Rounds    int         `json:"rounds"`
+	Seeding   string      `json:"seeding"`
+	Scoring   string      `json:"scoring"`
+	RoundTime int         `json:"roundTime"`
+	SeedHash  common.Hash `json:"seedHash"`
+}
+
+// StartRoundPayload is the payload for starting a round, the first round reveals the seed the
+// tournament committed to and later rounds need no payload
+type StartRoundPayload struct {
+	Seed []byte `json:"seed"`
+}
+
+// Standing is a player's position in a tournament
+type Standing struct {
+	Player common.Address `json:"player"`
+	Score  float64        `json:"score"`
+	Boards int            `json:"boards"`
+	Powers []string       `json:"powers"`
+}
+
+// TournamentEnd is published when the last board of a tournament finishes
+type TournamentEnd struct {
+	TournamentID int        `json:"tournamentID"`
+	Standings    []Standing `json:"standings"`
+}
+
+func (a *GameApplication) handleCreateTournament(
+	metadata rollmelette.Metadata,
+	inputPayload CreateTournamentPayload,
+) (*Tournament, error) {
+	if inputPayload.Rounds < 1 {
+		return nil, fmt.Errorf("tournament needs at least one round")
+	}
+	seeding := inputPayload.Seeding
+	if seeding == "" {
+		seeding = RandomSeeding
+	}
+	if seeding != RandomSeeding && seeding != RatingSeeding {
+		return nil, fmt.Errorf("invalid seeding: %v", seeding)
+	}
+	scoring := inputPayload.Scoring
+	if scoring == "" {
+		scoring = DefaultScoring
+	}
+	_, err := scoringSystem(scoring)
+	if err != nil {
+		return nil, err
+	}
+	if inputPayload.SeedHash == (common.Hash{}) {
+		return nil, fmt.Errorf("tournament needs a seed hash")
+	}
+
+	a.nextTournamentID++
+	tournament := &Tournament{
+		ID:        a.nextTournamentID,
+		Status:    TournamentOpen,
+		Organizer: metadata.MsgSender,
+		Rounds:    inputPayload.Rounds,
+		Seeding:   seeding,
+		Scoring:   scoring,
+		RoundTime: inputPayload.RoundTime,
+		Variant:   ClassicVariant,
+		SeedHash:  inputPayload.SeedHash,
+		Players:   []common.Address{},
+		Boards:    [][]int{},
+	}
+	a.tournaments[tournament.ID] = tournament
+	return tournament, nil
+}
+
+func (a *GameApplication) handleJoinTournament(
+	metadata rollmelette.Metadata,
+	tournamentID int,
+) (*Tournament, error) {
+	tournament, ok := a.tournaments[tournamentID]
+	if !ok {
+		return nil, fmt.Errorf("tournament not found: %v", tournamentID)
+	}
+	if tournament.Status != TournamentOpen {
+		return nil, fmt.Errorf("tournament is not open to new players")
+	}
+	for _, player := range tournament.Players {
+		if player == metadata.MsgSender {
+			return nil, fmt.Errorf("player already joined this tournament")
+		}
+	}
+	tournament.Players = append(tournament.Players, metadata.MsgSender)
+	return tournament, nil
+}
+
+// handleStartRound lets the organizer seed the next round and start all of its boards at once,
+// every board of the previous round must be finished and the first round reveals the seed
+func (a *GameApplication) handleStartRound(
+	metadata rollmelette.Metadata,
+	tournamentID int,
+	inputPayload StartRoundPayload,
+) (*Tournament, error) {
+	tournament, ok := a.tournaments[tournamentID]
+	if !ok {
+		return nil, fmt.Errorf("tournament not found: %v", tournamentID)
+	}
+	if tournament.Organizer != metadata.MsgSender {
+		return nil, fmt.Errorf("only the organizer can start a round")
+	}
+	if len(tournament.Boards) == tournament.Rounds {
+		return nil, fmt.Errorf("every round was already played")
+	}
+	powers := variants[tournament.Variant].powers()
+	if len(tournament.Players) == 0 || len(tournament.Players)%len(powers) != 0 {
+		return nil, fmt.Errorf("tournament needs a multiple of %v players, has %v", len(powers), len(tournament.Players))
+	}
+	for _, game := range a.tournamentGames(tournament) {
+		if game.Status != GameFinished {
+			return nil, fmt.Errorf("previous round is not finished")
+		}
+	}
+
+	if tournament.Seed == nil {
+		if crypto.Keccak256Hash(inputPayload.Seed) != tournament.SeedHash {
+			return nil, fmt.Errorf("seed doesn't match the tournament's seed hash")
+		}
+		tournament.Seed = inputPayload.Seed
+		tournament.Closing = crypto.Keccak256Hash([]byte(fmt.Sprintf(
+			"%d %d %d", metadata.InputIndex, metadata.BlockNumber, metadata.BlockTimestamp,
+		)))
+	}
+
+	rng := rand.New(rand.NewSource(roundSeed(tournament)))
+	boards := a.seedBoards(tournament, rng)
+
+	round := make([]int, 0, len(boards))
+	for _, seats := range boards {
+		game := newGame(a.nextGameID, tournament.Organizer, big.NewInt(0), tournament.RoundTime)
+		game.Tournament = tournament.ID
+		game.Variant = tournament.Variant
+		game.Scoring = tournament.Scoring
+		game.Seats = seats
+		game.Players = len(seats)
+		game.start()
+		a.games[game.ID] = game
+		a.nextGameID++
+		round = append(round, game.ID)
+	}
+	tournament.Boards = append(tournament.Boards, round)
+	tournament.Status = TournamentRunning
+	return tournament, nil
+}
+
+// roundSeed derives the seed of a round's draw from the organizer's seed and the block data of the
+// input that closed registration, which didn't exist when the players registered
+// This only narrows what the organizer controls: they know their seed, so they can still time the
+// closing input to try a few blocks, register entrants of their own or never reveal the seed
+func roundSeed(tournament *Tournament) int64 {
+	hash := crypto.Keccak256(tournament.Seed, tournament.Closing.Bytes(), []byte(fmt.Sprintf(
+		"diplomacy tournament %d round %d", tournament.ID, len(tournament.Boards),
+	)))
+	return int64(binary.BigEndian.Uint64(hash))
+}
+
+// seedBoards splits the players into boards of seven and orders each board by seat
+// Players are laid out in tiers of one player per board, with rating seeding the tiers go from
+// the highest to the lowest rated and alternate direction so the boards stay balanced
+// Players of the same tier are then swapped between boards while it reduces the number of
+// pairs that already met, and each board's seats are arranged to avoid repeating powers
+func (a *GameApplication) seedBoards(tournament *Tournament, rng *rand.Rand) [][]common.Address {
+	players := append([]common.Address{}, tournament.Players...)
+	if tournament.Seeding == RatingSeeding {
+		sort.SliceStable(players, func(i, j int) bool {
+			ri, rj := a.rating(players[i]), a.rating(players[j])
+			if ri != rj {
+				return ri > rj
+			}
+			return players[i].Hex() < players[j].Hex()
+		})
+	} else {
+		rng.Shuffle(len(players), func(i, j int) {
+			players[i], players[j] = players[j], players[i]
+		})
+	}
+
+	powers := variants[tournament.Variant].powers()
+	count := len(players) / len(powers)
+	boards := make([][]common.Address, count)
+	for tier := 0; tier < len(powers); tier++ {
+		for i := 0; i < count; i++ {
+			board := i
+			if tier%2 == 1 {
+				board = count - 1 - i
+			}
+			boards[board] = append(boards[board], players[tier*count+i])
+		}
+	}
+
+	met, played := a.tournamentHistory(tournament)
+	for improved := true; improved; {
+		improved = false
+		for tier := 0; tier < len(powers); tier++ {
+			for i := 0; i < count; i++ {
+				for j := i + 1; j < count; j++ {
+					before := meetings(boards[i], met) + meetings(boards[j], met)
+					boards[i][tier], boards[j][tier] = boards[j][tier], boards[i][tier]
+					if meetings(boards[i], met)+meetings(boards[j], met) < before {
+						improved = true
+					} else {
+						boards[i][tier], boards[j][tier] = boards[j][tier], boards[i][tier]
+					}
+				}
+			}
+		}
+	}
+
+	for i, board := range boards {
+		boards[i] = assignPowers(board, powers, played)
+	}
+	return boards
+}
+
+type pairing [2]common.Address
+
+func pair(p1 common.Address, p2 common.Address) pairing {
+	if p1.Hex() > p2.Hex() {
+		p1, p2 = p2, p1
+	}
+	return pairing{p1, p2}
+}
+
+// meetings counts how many times the players of a board already met in earlier rounds
+func meetings(board []common.Address, met map[pairing]int) int {
+	count := 0
+	for i := range board {
+		for j := i + 1; j < len(board); j++ {
+			count += met[pair(board[i], board[j])]
+		}
+	}
+	return count
+}
+
+// assignPowers orders the board's seats so the fewest players get a power they already played,
+// seat i playing the i-th of the powers, keeping the original order between equally good
+// arrangements
+func assignPowers(board []common.Address, powers []string, played map[common.Address]map[string]bool) []common.Address {
+	repeats := func(seats []common.Address) int {
+		count := 0
+		for i, player := range seats {
+			if played[player][powers[i]] {
+				count++
+			}
+		}
+		return count
+	}
+
+	best := append([]common.Address{}, board...)
+	bestRepeats := repeats(best)
+	seats := append([]common.Address{}, board...)
+	var permute func(k int)
+	permute = func(k int) {
+		if bestRepeats == 0 {
+			return
+		}
+		if k == len(seats) {
+			if r := repeats(seats); r < bestRepeats {
+				best, bestRepeats = append([]common.Address{}, seats...), r
+			}
+			return
+		}
+		for i := k; i < len(seats); i++ {
+			seats[k], seats[i] = seats[i], seats[k]
+			permute(k + 1)
+			seats[k], seats[i] = seats[i], seats[k]
+		}
+	}
+	permute(0)
+	return best
+}
+
+// tournamentHistory returns how many times each pair of players shared a board and which powers
+// each player already played
+func (a *GameApplication) tournamentHistory(tournament *Tournament) (map[pairing]int, map[common.Address]map[string]bool) {
+	met := make(map[pairing]int)
+	played := make(map[common.Address]map[string]bool)
+	for _, game := range a.tournamentGames(tournament) {
+		powers := game.variant().powers()
+		for i, player := range game.Seats {
+			if played[player] == nil {
+				played[player] = make(map[string]bool)
+			}
+			played[player][powers[i]] = true
+			for _, other := range game.Seats[i+1:] {
+				met[pair(player, other)]++
+			}
+		}
+	}
+	return met, played
+}
+
+func (a *GameApplication) tournamentGames(tournament *Tournament) []*Game {
+	var games []*Game
+	for _, round := range tournament.Boards {
+		for _, id := range round {
+			games = append(games, a.games[id])
+		}
+	}
+	return games
+}
+
+// standings adds up the scores of every finished board, highest total first
+func (a *GameApplication) standings(tournament *Tournament) []Standing {
+	byPlayer := make(map[common.Address]*Standing, len(tournament.Players))
+	for _, player := range tournament.Players {
+		byPlayer[player] = &Standing{Player: player, Powers: []string{}}
+	}
+	for _, game := range a.tournamentGames(tournament) {
+		powers := game.variant().powers()
+		for i, player := range game.Seats {
+			standing := byPlayer[player]
+			standing.Powers = append(standing.Powers, powers[i])
+			if game.Status == GameFinished {
+				standing.Score += game.Scores[powers[i]]
+				standing.Boards++
+			}
+		}
+	}
+
+	standings := make([]Standing, 0, len(byPlayer))
+	for _, player := range tournament.Players {
+		standings = append(standings, *byPlayer[player])
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].Player.Hex() < standings[j].Player.Hex()
+	})
+	return standings
+}
+
+// checkTournament finishes the tournament and publishes the final standings once every board
+// of the last round is finished
+func (a *GameApplication) checkTournament(env rollmelette.Env, tournament *Tournament) error {
+	if len(tournament.Boards) != tournament.Rounds {
+		return nil
+	}
+	for _, game := range a.tournamentGames(tournament) {
+		if game.Status != GameFinished {
+			return nil
+		}
+	}
+	tournament.Status = TournamentFinished
+	return emitNotice(env, TournamentEndNotice, TournamentEnd{
+		TournamentID: tournament.ID,
+		Standings:    a.standings(tournament),
+	})
+}
+
+// TournamentView is a tournament along with its current standings
+type TournamentView struct {
+	*Tournament
+	Standings []Standing `json:"standings"`
+}
+
+func queryTournaments(a *GameApplication, args QueryArgs) (any, error) {
+	tournaments := []*Tournament{}
+	for _, tournament := range a.tournaments {
+		tournaments = append(tournaments, tournament)
+	}
+	sort.Slice(tournaments, func(i, j int) bool {
+		return tournaments[i].ID < tournaments[j].ID
+	})
+	return tournaments, nil
+}
+
+func queryTournament(a *GameApplication, args QueryArgs) (any, error) {
+	tournament, ok := a.tournaments[args.Tournament]
+	if !ok {
+		return nil, fmt.Errorf("tournament not found: %v", args.Tournament)
+	}
+	return TournamentView{tournament, a.standings(tournament)}, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rollmelette/rollmelette"
+)
+
+type TreatyStatus string
+
+// Treaty statuses
+const (
+	TreatyProposed  TreatyStatus = "proposed"
+	TreatyActive    TreatyStatus = "active"
+	TreatyExpired   TreatyStatus = "expired"
+	TreatyWithdrawn TreatyStatus = "withdrawn"
+)
+
+// Treaty kinds the engine knows how to enforce
+// A non aggression pact forbids its parties to move or support a move into each other's home
+// centers, a demilitarized zone forbids them to move or support a move into its regions
+const (
+	NonAggression = "nonAggression"
+	DMZ           = "dmz"
+)
+
+// Treaty binds its parties once every one of them accepted it, orders breaking it are rejected
+// Parties lists every power bound, the Proposer first, and Accepted the ones that signed it
+// The treaty stays active for Years game years, until the game reaches the year it Expires
+type Treaty struct {
+	ID       int          `json:"id"`
+	Status   TreatyStatus `json:"status"`
+	Kind     string       `json:"kind"`
+	Proposer string       `json:"proposer"`
+	Parties  []string     `json:"parties"`
+	Accepted []string     `json:"accepted"`
+	Regions  []string     `json:"regions"`
+	Years    int          `json:"years"`
+	Expires  int          `json:"expires"`
+}
+
+// ProposeTreatyPayload is the payload for proposing a treaty
+// From is the power proposing it, it can be left out by players controlling a single power
+// To lists the other powers that must accept it before it binds
+// Regions are the regions of a demilitarized zone
+type ProposeTreatyPayload struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Kind    string   `json:"kind"`
+	Regions []string `json:"regions"`
+	Years   int      `json:"years"`
+}
+
+// TreatyPayload is the payload for accepting or withdrawing a treaty on behalf of a Power
+type TreatyPayload struct {
+	TreatyID int    `json:"treatyID"`
+	Power    string `json:"power"`
+}
+
+func (a *Game) handleProposeTreaty(
+	metadata rollmelette.Metadata,
+	inputPayload ProposeTreatyPayload,
+) error {
+	if !a.Treaties {
+		return fmt.Errorf("treaties are not enabled in this game")
+	}
+	team, err := a.sender(metadata.MsgSender, inputPayload.From)
+	if err != nil {
+		return err
+	}
+	if len(inputPayload.To) == 0 {
+		return fmt.Errorf("treaties need another power")
+	}
+	parties := []string{team.Name}
+	for _, power := range inputPayload.To {
+		if _, ok := a.state.Players[power]; !ok {
+			return fmt.Errorf("power not found: %v", power)
+		}
+		if contains(parties, power) {
+			return fmt.Errorf("power listed twice: %v", power)
+		}
+		parties = append(parties, power)
+	}
+	switch inputPayload.Kind {
+	case NonAggression:
+		if len(inputPayload.Regions) != 0 {
+			return fmt.Errorf("non aggression pacts don't name regions")
+		}
+	case DMZ:
+		if len(inputPayload.Regions) == 0 {
+			return fmt.Errorf("demilitarized zones need regions")
+		}
+		for i, region := range inputPayload.Regions {
+			inputPayload.Regions[i], err = a.resolveRegion(region)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("invalid treaty kind: %v", inputPayload.Kind)
+	}
+	if inputPayload.Years < 1 {
+		return fmt.Errorf("treaties last at least a year")
+	}
+
+	a.state.Treaties = append(a.state.Treaties, &Treaty{
+		ID:       len(a.state.Treaties) + 1,
+		Status:   TreatyProposed,
+		Kind:     inputPayload.Kind,
+		Proposer: team.Name,
+		Parties:  parties,
+		Accepted: []string{team.Name},
+		Regions:  inputPayload.Regions,
+		Years:    inputPayload.Years,
+	})
+	return nil
+}
+
+// handleAcceptTreaty signs the treaty, it binds from the moment the last party signs it and the
+// orders its parties gave before that and break it are changed into holds
+func (a *Game) handleAcceptTreaty(
+	metadata rollmelette.Metadata,
+	inputPayload TreatyPayload,
+) error {
+	treaty, err := a.treaty(inputPayload.TreatyID)
+	if err != nil {
+		return err
+	}
+	team, err := a.sender(metadata.MsgSender, inputPayload.Power)
+	if err != nil {
+		return err
+	}
+	if treaty.Status != TreatyProposed {
+		return fmt.Errorf("treaty is not open to signatures")
+	}
+	if !contains(treaty.Parties, team.Name) {
+		return fmt.Errorf("%v is not a party of the treaty", team.Name)
+	}
+	if contains(treaty.Accepted, team.Name) {
+		return fmt.Errorf("%v already accepted the treaty", team.Name)
+	}
+
+	treaty.Accepted = append(treaty.Accepted, team.Name)
+	if len(treaty.Accepted) == len(treaty.Parties) {
+		treaty.Status = TreatyActive
+		treaty.Expires = a.state.Year + treaty.Years
+		a.enforceTreaties()
+	}
+	return nil
+}
+
+// enforceTreaties turns into holds the current orders that break an active treaty
+func (a *Game) enforceTreaties() {
+	if a.state.Turn != "move" {
+		return
+	}
+	for _, unit := range a.state.Units {
+		if a.checkTreaties(unit, unit.CurrentOrder) != nil {
+			unit.CurrentOrder = Orders{UnitID: unit.ID, Ordertype: "hold"}
+		}
+	}
+}
+
+// handleWithdrawTreaty lets the proposer take back a treaty nobody is bound by yet
+func (a *Game) handleWithdrawTreaty(
+	metadata rollmelette.Metadata,
+	inputPayload TreatyPayload,
+) error {
+	treaty, err := a.treaty(inputPayload.TreatyID)
+	if err != nil {
+		return err
+	}
+	team, err := a.sender(metadata.MsgSender, inputPayload.Power)
+	if err != nil {
+		return err
+	}
+	if treaty.Proposer != team.Name {
+		return fmt.Errorf("only the proposer can withdraw the treaty")
+	}
+	if treaty.Status != TreatyProposed {
+		return fmt.Errorf("can't withdraw a treaty that is not proposed")
+	}
+	treaty.Status = TreatyWithdrawn
+	return nil
+}
+
+func (a *Game) treaty(id int) (*Treaty, error) {
+	if id < 1 || id > len(a.state.Treaties) {
+		return nil, fmt.Errorf("treaty not found: %v", id)
+	}
+	return a.state.Treaties[id-1], nil
+}
+
+// expireTreaties ends the treaties whose years are over, it runs as a new year starts
+func (a *Game) expireTreaties() {
+	for _, treaty := range a.state.Treaties {
+		if treaty.Status == TreatyActive && a.state.Year >= treaty.Expires {
+			treaty.Status = TreatyExpired
+		}
+	}
+}
+
+// checkTreaties rejects an order moving, or supporting a move, somewhere an active treaty of the
+// unit's power keeps it out of
+func (a *Game) checkTreaties(unit *Unit, order Orders) error {
+	if order.Ordertype != "move" && order.Ordertype != "convoy move" && order.Ordertype != "support move" {
+		return nil
+	}
+	for _, treaty := range a.state.Treaties {
+		if treaty.Status != TreatyActive || !contains(treaty.Parties, unit.Power) {
+			continue
+		}
+		switch treaty.Kind {
+		case DMZ:
+			if contains(treaty.Regions, order.ToRegion) {
+				return fmt.Errorf("order breaks treaty %v: %v is demilitarized", treaty.ID, order.ToRegion)
+			}
+		case NonAggression:
+			home := a.state.Board[order.ToRegion].Home
+			if home != unit.Power && contains(treaty.Parties, home) {
+				return fmt.Errorf("order breaks treaty %v: %v is a home center of %v", treaty.ID, order.ToRegion, home)
+			}
+		}
+	}
+	return nil
+}
+
+// queryTreaties lists the treaties of the game, only the ones the power is a party of when given
+func queryTreaties(a *Game, args QueryArgs) (any, error) {
+	treaties := []*Treaty{}
+	for _, treaty := range a.state.Treaties {
+		if args.Power == "" || contains(treaty.Parties, args.Power) {
+			treaties = append(treaties, treaty)
+		}
+	}
+	return treaties, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+)
+
+// Variant used when a game doesn't ask for one
+const ClassicVariant = "classic"
+
+// Build rules of a variant
+const (
+	HomeBuilds  = "home"
+	OwnedBuilds = "owned"
+)
+
+//go:embed variants/*.json
+var variantFiles embed.FS
+
+// variants holds every variant shipped with the application by name
+var variants = mustLoadVariants()
+
+// Variant is a way of playing the game: the map, who plays it, where they start and what it
+// takes to win
+// Powers lists the powers taking part in the order seats are handed to players, every power of
+// the map by default, supply centers of the powers left out start neutral
+// Centers hands supply centers to powers, the center becomes the power's home and starts owned
+// by it, letting variants bring powers of their own to the map
+// Units are the starting units, the map's units of the powers taking part by default
+// VictoryCenters is the number of supply centers a power needs to win the game alone
+// BuildRule is home when units are only built in home supply centers still controlled, owned
+// when they can be built in any supply center controlled
+type Variant struct {
+	Name           string            `json:"name"`
+	Map            string            `json:"map"`
+	Powers         []string          `json:"powers,omitempty"`
+	Centers        map[string]string `json:"centers,omitempty"`
+	Units          []UnitDefinition  `json:"units,omitempty"`
+	VictoryCenters int               `json:"victoryCenters"`
+	BuildRule      string            `json:"buildRule"`
+}
+
+func mustLoadVariants() map[string]*Variant {
+	entries, err := variantFiles.ReadDir("variants")
+	if err != nil {
+		panic(err)
+	}
+	variants := make(map[string]*Variant, len(entries))
+	for _, entry := range entries {
+		bytes, err := variantFiles.ReadFile(path.Join("variants", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var variant Variant
+		err = json.Unmarshal(bytes, &variant)
+		if err != nil {
+			panic(fmt.Errorf("failed to unmarshal variant %v: %w", entry.Name(), err))
+		}
+		variants[variant.Name] = &variant
+	}
+	return variants
+}
+
+func variantDefinition(name string) (*Variant, error) {
+	variant, ok := variants[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid variant: %v", name)
+	}
+	err := variant.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("variant %v is not valid: %w", name, err)
+	}
+	return variant, nil
+}
+
+// Validate checks the variant's map and the board it sets up, and that the victory can be reached
+func (v *Variant) Validate() error {
+	_, err := mapDefinition(v.Map)
+	if err != nil {
+		return err
+	}
+	definition := v.definition()
+	var problems []error
+	for _, power := range v.Powers {
+		if !contains(maps[v.Map].Powers, power) && !v.hasCenter(power) {
+			problems = append(problems, fmt.Errorf("power %v is not a power of map %v", power, v.Map))
+		}
+	}
+	for center, power := range v.Centers {
+		region := maps[v.Map].region(center)
+		if region == nil || !region.SupplyCenter {
+			problems = append(problems, fmt.Errorf("%v is not a supply center of map %v", center, v.Map))
+		}
+		if !contains(definition.Powers, power) {
+			problems = append(problems, fmt.Errorf("supply center %v is handed to unknown power %v", center, power))
+		}
+	}
+	if len(definition.Powers) == 0 {
+		problems = append(problems, fmt.Errorf("variant has no powers"))
+	}
+	err = definition.Validate()
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	centers := 0
+	for _, region := range definition.Regions {
+		if region.SupplyCenter {
+			centers++
+		}
+	}
+	if v.VictoryCenters <= 0 || v.VictoryCenters > centers {
+		problems = append(problems, fmt.Errorf("victory needs between 1 and %v supply centers, not %v", centers, v.VictoryCenters))
+	}
+	if v.BuildRule != HomeBuilds && v.BuildRule != OwnedBuilds {
+		problems = append(problems, fmt.Errorf("invalid build rule: %v", v.BuildRule))
+	}
+	return errors.Join(problems...)
+}
+
+// powers lists the powers taking part in the variant
+func (v *Variant) powers() []string {
+	if len(v.Powers) != 0 {
+		return v.Powers
+	}
+	return maps[v.Map].Powers
+}
+
+// hasCenter tells whether the variant hands a supply center to the power
+func (v *Variant) hasCenter(power string) bool {
+	for _, owner := range v.Centers {
+		if owner == power {
+			return true
+		}
+	}
+	return false
+}
+
+// definition is the variant's map as the game starts, with only the powers taking part
+func (v *Variant) definition() *MapDefinition {
+	board := maps[v.Map]
+	definition := *board
+	definition.Powers = v.powers()
+
+	definition.Regions = make([]RegionDefinition, 0, len(board.Regions))
+	for _, region := range board.Regions {
+		if power, ok := v.Centers[region.Name]; ok {
+			region.Owner = power
+			region.Home = power
+		}
+		if !contains(definition.Powers, region.Owner) {
+			region.Owner = ""
+		}
+		if !contains(definition.Powers, region.Home) {
+			region.Home = ""
+		}
+		definition.Regions = append(definition.Regions, region)
+	}
+
+	definition.Units = v.Units
+	if len(v.Units) == 0 {
+		definition.Units = []UnitDefinition{}
+		for _, unit := range board.Units {
+			if contains(definition.Powers, unit.Power) {
+				definition.Units = append(definition.Units, unit)
+			}
+		}
+	}
+	return &definition
+}
+
+// variant is the variant the game is played with
+func (a *Game) variant() *Variant {
+	return variants[a.Variant]
+}